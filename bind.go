@@ -0,0 +1,127 @@
+package conf
+
+import (
+	"log"
+	"sync"
+	"sync/atomic"
+)
+
+/*
+ * 绑定到配置项上的类型化的值
+ * 配置重新加载之后会重新解析并原子地替换，Load总是返回一份完整一致的快照
+ * 适合在请求处理等热点路径上使用，避免每次都通过字符串key获取配置
+ */
+type Value[T any] struct {
+	name string
+	key  string
+	ptr  atomic.Pointer[T]
+}
+
+/*
+ * 返回当前的值，不要修改返回值中的map和slice，它们和其他读取者共享
+ */
+func (v *Value[T]) Load() T {
+	return *v.ptr.Load()
+}
+
+/*
+ * 使用新的配置重新解析，失败时保留原来的值
+ */
+func (v *Value[T]) rebind(conf *MConfig) error {
+	var val T
+	if err := unmarshalKey(conf, v.key, &val); err != nil {
+		return err
+	}
+	v.ptr.Store(&val)
+	return nil
+}
+
+// 监听器在配置重新加载后调用
+type binding interface {
+	rebind(conf *MConfig) error
+}
+
+/*
+ * 把配置confName中key对应的值绑定到T上，key为空时绑定整个配置
+ * 解析规则和Unmarshal相同，配置更新后返回的Value会自动更新
+ */
+func Bind[T any](confName string, key string) (*Value[T], error) {
+	return BindFrom[T](configManager, confName, key)
+}
+
+/*
+ * 和Bind相同，绑定的是管理器m中的配置
+ */
+func BindFrom[T any](m *MConfigManager, confName string, key string) (*Value[T], error) {
+	v := &Value[T]{name: confName, key: key}
+	// 和发布者的rebind串行执行，解析期间发布的新配置会在之后重新绑定到v上
+	lock := m.rebindLock(confName)
+	lock.Lock()
+	defer lock.Unlock()
+	m.bindLock.Lock()
+	m.bindings[confName] = append(m.bindings[confName], v)
+	m.bindLock.Unlock()
+	if err := v.rebind(m.MultiConfig(confName)); err != nil {
+		m.unbind(confName, v)
+		return nil, err
+	}
+	return v, nil
+}
+
+func (m *MConfigManager) unbind(confName string, b binding) {
+	m.bindLock.Lock()
+	defer m.bindLock.Unlock()
+	bindings := m.bindings[confName]
+	for i, item := range bindings {
+		if item == b {
+			m.bindings[confName] = append(bindings[:i:i], bindings[i+1:]...)
+			break
+		}
+	}
+	if len(m.bindings[confName]) == 0 {
+		delete(m.bindings, confName)
+	}
+}
+
+func unmarshalKey(conf *MConfig, key string, out interface{}) error {
+	if key == "" {
+		return conf.UnmarshalAll(out)
+	}
+	return conf.Unmarshal(key, out)
+}
+
+/*
+ * 配置更新之后，重新解析所有绑定在这个配置上的值
+ * 同一个配置的重新解析串行执行，并且使用执行时最新的配置而不是发布时的配置，
+ * ReplaceConfig和monitor同时发布时，不管哪一个先完成，最后绑定的都是最新的配置
+ */
+func (m *MConfigManager) rebind(confName string) {
+	lock := m.rebindLock(confName)
+	lock.Lock()
+	defer lock.Unlock()
+	// 配置已经被删除时保留删除之前的值
+	val, ok := m.confs.Load(confName)
+	if !ok {
+		return
+	}
+	conf := val.(*MConfig)
+	m.bindLock.Lock()
+	bindings := append([]binding{}, m.bindings[confName]...)
+	m.bindLock.Unlock()
+	for _, b := range bindings {
+		if err := b.rebind(conf); err != nil {
+			log.Printf("rebind config:%s failed, keep the old value, error:%s\n", confName, err.Error())
+		}
+	}
+}
+
+func (m *MConfigManager) rebindLock(confName string) *sync.Mutex {
+	m.bindLock.Lock()
+	defer m.bindLock.Unlock()
+	lock, ok := m.rebindLocks[confName]
+	if !ok {
+		lock = &sync.Mutex{}
+		m.rebindLocks[confName] = lock
+	}
+	return lock
+}
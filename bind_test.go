@@ -0,0 +1,121 @@
+package conf
+
+import (
+	"conf/fileutil"
+	"path/filepath"
+	"sync"
+	"testing"
+)
+
+type testDB struct {
+	Host string `conf:"host"`
+	Port int    `conf:"port" default:"3306"`
+}
+
+func TestBind(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "bind.json")
+	fileutil.WriteContent(path, `{"db": {"host": "127.0.0.1"}}`)
+	SetConfig("bind", path, nil)
+	// Config()会返回任意一个配置，测试结束后移除，避免影响其他测试
+	t.Cleanup(func() {
+		configManager.confs.Delete("bind")
+		configManager.bindLock.Lock()
+		delete(configManager.bindings, "bind")
+		configManager.bindLock.Unlock()
+	})
+
+	db, err := Bind[testDB]("bind", "db")
+	if err != nil {
+		t.Fatalf("Bind error:%+v", err)
+	}
+	if val := db.Load(); val.Host != "127.0.0.1" || val.Port != 3306 {
+		t.Errorf("Bind Load = %+v; expected host 127.0.0.1 port 3306", val)
+	}
+	snapshot := db.Load()
+
+	// 模拟monitor重新加载配置
+	fileutil.WriteContent(path, `{"db": {"host": "10.0.0.1", "port": 3307}}`)
+	updated, err := MultiConfig("bind").reload()
+	if err != nil {
+		t.Fatalf("reload error:%+v", err)
+	}
+	configManager.confs.Store("bind", updated)
+	configManager.rebind("bind")
+	if val := db.Load(); val.Host != "10.0.0.1" || val.Port != 3307 {
+		t.Errorf("Bind Load after reload = %+v; expected host 10.0.0.1 port 3307", val)
+	}
+	if snapshot.Host != "127.0.0.1" {
+		t.Errorf("old snapshot changed to %+v", snapshot)
+	}
+
+	all, err := Bind[map[string]testDB]("bind", "")
+	if err != nil || all.Load()["db"].Host != "10.0.0.1" {
+		t.Errorf("Bind whole config = %+v, error:%+v", all.Load(), err)
+	}
+
+	// 解析失败时保留原来的值
+	fileutil.WriteContent(path, `{"db": {"host": "10.0.0.2", "port": "not a number"}}`)
+	updated, err = MultiConfig("bind").reload()
+	if err != nil {
+		t.Fatalf("reload error:%+v", err)
+	}
+	configManager.confs.Store("bind", updated)
+	configManager.rebind("bind")
+	if val := db.Load(); val.Host != "10.0.0.1" || val.Port != 3307 {
+		t.Errorf("Bind Load after bad reload = %+v; expected the old value", val)
+	}
+
+	if _, err := Bind[testDB]("bind", "not_exist"); err == nil {
+		t.Errorf("Bind not exist key should fail")
+	}
+}
+
+func TestBindConcurrentReplace(t *testing.T) {
+	dir := t.TempDir()
+	paths := []string{filepath.Join(dir, "a.json"), filepath.Join(dir, "b.json")}
+	fileutil.WriteContent(paths[0], `{"db": {"host": "a"}}`)
+	fileutil.WriteContent(paths[1], `{"db": {"host": "b"}}`)
+	m := NewManager()
+	if err := m.SetConfig("bind", paths[0], nil); err != nil {
+		t.Fatalf("SetConfig error:%+v", err)
+	}
+
+	// 多个发布者同时替换配置，各自的rebind可能交错执行
+	var wg sync.WaitGroup
+	for p := 0; p < 2; p++ {
+		wg.Add(1)
+		go func(p int) {
+			defer wg.Done()
+			for i := 0; i < 50; i++ {
+				m.ReplaceConfig("bind", paths[(i+p)%2], nil)
+			}
+		}(p)
+	}
+	values := make([]*Value[testDB], 0)
+	for i := 0; i < 50; i++ {
+		v, err := BindFrom[testDB](m, "bind", "db")
+		if err != nil {
+			t.Fatalf("BindFrom error:%+v", err)
+		}
+		values = append(values, v)
+	}
+	wg.Wait()
+
+	// 绑定期间发生的替换不会让值停留在旧的配置上
+	expected, _ := m.MultiConfig("bind").GetString("db.host")
+	for i, v := range values {
+		if host := v.Load().Host; host != expected {
+			t.Errorf("values[%d].Load().Host = %s; expected %s", i, host, expected)
+		}
+	}
+
+	if _, err := BindFrom[testDB](m, "bind", "not_exist"); err == nil {
+		t.Errorf("BindFrom not exist key should fail")
+	}
+	m.bindLock.Lock()
+	bound := len(m.bindings["bind"])
+	m.bindLock.Unlock()
+	if bound != len(values) {
+		t.Errorf("bindings = %d; expected %d, failed Bind should not be registered", bound, len(values))
+	}
+}
@@ -1,21 +1,37 @@
 package conf
 
 import (
-	"bytes"
 	"conf/fileutil"
 	"encoding/json"
 	"errors"
 	"log"
+	"os"
 	"path/filepath"
-	"strconv"
 	"strings"
 	"sync"
+	"time"
 )
 
 var TypeErr = errors.New("invalid type")
 var KeyNotFoundErr = errors.New("not found key")
 var InvalidKeyErr = errors.New("invalid key")
 var InvalidSliceIndexErr = errors.New("invalid slice index")
+var InvalidTimeErr = errors.New("invalid time")
+
+// GetTime支持的时间格式，依次尝试
+const (
+	localDatetimeLayout = "2006-01-02T15:04:05.999999999"
+	localDateLayout     = "2006-01-02"
+	localTimeLayout     = "15:04:05.999999999"
+)
+
+var timeLayouts = []string{
+	time.RFC3339Nano,
+	localDatetimeLayout,
+	"2006-01-02 15:04:05.999999999",
+	localDateLayout,
+	localTimeLayout,
+}
 
 type MConfig struct {
 	// 配置所在的目录
@@ -24,210 +40,303 @@ type MConfig struct {
 	filename string
 	// 配置文件路径
 	path string
-	// md5
-	md5 string
+	// 文件内容的hash，算法由opts.hash决定
+	sum string
+	// 读取时文件的修改时间和大小
+	stat fileStat
+	// 解析符号链接之后真正读取的文件，ConfigMap之类通过替换符号链接更新的文件，内容相同时也需要感知
+	target string
+	// 配置文件格式
+	format Format
+	// SetConfig时传入的选项，重新加载时沿用
+	opts configOptions
+	// 分层配置的每一层，按优先级从低到高排列，单个文件的配置为空
+	layers []*MConfig
 	// 解析过的配置项
 	parsedEntryMap sync.Map
+	// 不规范的key解析之后的规范写法，见resolveKey
+	resolvedKeys sync.Map
+	// key对应的环境变量名，避免每次Get*都重新拼接，见lookupEnv
+	envNames sync.Map
 	// 未解析的配置项
 	rawEntryMap map[string]json.RawMessage
-	locker      sync.Mutex
+	// 原生日期时间的位置，key为规范写法的完整key，只有toml有
+	datetimes map[string]DatetimeKind
+	// 加载时由rawEntryMap建立的节点树，Get*在树上查找
+	root   *node
+	locker sync.Mutex
 }
 
-func newMConfig(p string) *MConfig {
+func newMConfig(p string, opts configOptions) (*MConfig, error) {
+	if opts.flags == nil {
+		opts.flags = newFlagOverlay()
+	}
 	// 空的conf
 	if p == "" {
 		return &MConfig{
 			rawEntryMap: make(map[string]json.RawMessage, 0),
-		}
+			root:        &node{kind: objectNode, fields: make(map[string]*node, 0)},
+			opts:        opts,
+		}, nil
 	}
-	if ok, err := fileutil.IsFile(p); ok == false {
-		log.Printf("file:%s not exist, err:%+v", p, err)
-		return nil
+	if fi, err := os.Stat(p); err != nil {
+		return nil, &LoadError{Path: p, Err: err}
+	} else if !fi.Mode().IsRegular() {
+		return nil, &LoadError{Path: p, Err: errors.New("not a regular file")}
 	}
 	cf := &MConfig{
 		rawEntryMap: make(map[string]json.RawMessage, 0),
 		dir:         filepath.Dir(p),
 		filename:    filepath.Base(p),
 		path:        p,
+		format:      detectFormat(p, opts.format),
+		opts:        opts,
 	}
 
-	content, err := fileutil.ReadContent(p)
+	// 从解析后的路径读取，避免读取过程中符号链接被替换导致内容和target不一致
+	target, err := filepath.EvalSymlinks(p)
 	if err != nil {
-		log.Printf("can't read file:%s content, error:%s\n", p, err.Error())
-		return nil
+		return nil, &LoadError{Path: p, Err: err}
 	}
-	cf.md5, err = fileutil.HashFileMd5(p)
+	cf.target = target
+	// 先取修改时间再读取，读取过程中的修改在下一次检查时仍然可以发现
+	cf.stat, err = statFile(target)
 	if err != nil {
-		log.Printf("can't get file:%s md5, error:%s\n", p, err.Error())
-		return nil
+		return nil, &LoadError{Path: p, Err: err}
 	}
-	err = json.Unmarshal(content, &cf.rawEntryMap)
+	content, err := fileutil.ReadContent(target)
 	if err != nil {
-		log.Printf("decode config fileutil:%s failed, error:%s\n", p, err.Error())
-		return cf
+		return nil, &LoadError{Path: p, Err: err}
 	}
-	return cf
-}
-
-func (m *MConfig) checkFileDiff() bool {
-	md5, err := fileutil.HashFileMd5(m.path)
+	cf.sum, err = opts.hash.sum(target)
 	if err != nil {
-		log.Printf("can't get file:%s md5, error:%s\n", m.path, err.Error())
-		return false
+		return nil, &LoadError{Path: p, Err: err}
+	}
+	// 解析失败时不能返回空的配置，否则一次错误的修改就会清空所有配置项
+	var rawMap map[string]json.RawMessage
+	if cf.format == TOMLFormat {
+		rawMap, cf.datetimes, err = decodeTOMLDatetimes(content)
+	} else {
+		rawMap, err = decodeContent(content, cf.format)
 	}
-	return md5 != m.md5
+	if err != nil {
+		return nil, newParseError(p, cf.format, content, err)
+	}
+	cf.rawEntryMap = rawMap
+	if cf.root, err = buildTree(rawMap); err != nil {
+		return nil, newParseError(p, cf.format, content, err)
+	}
+	markDatetimes(cf.root, cf.datetimes)
+	return cf, nil
 }
 
 /*
- * 遍历json串中的对象
- * travel 支持按照字符串的模式遍历json，例如传入key1.key2.key3的模式，特别针对数组的情况
- * 你可以使用key1.key2[1].key3的模式传入，函数会帮你解析数组下标
+ * 当前配置对应的文件内容的hash，分层配置为每一层的hash
  */
-func (m *MConfig) travel(key string, lastElemHandler func(val json.RawMessage, index int) (interface{}, error)) (string, interface{}, error) {
-	elems := strings.Split(key, ".")
-	rawMap := m.rawEntryMap
-
-	shapingKey := bytes.NewBuffer([]byte{})
+func (m *MConfig) hash() string {
+	if len(m.layers) > 0 {
+		hashes := make([]string, len(m.layers))
+		for i, layer := range m.layers {
+			hashes[i] = layer.sum
+		}
+		return strings.Join(hashes, ",")
+	}
+	return m.sum
+}
 
-	// 对每个split开来的单元进行处理
-	for i, elem := range elems {
-		if elem == "" {
-			return "", nil, InvalidKeyErr
+/*
+ * 配置对应的文件版本，由内容的hash和符号链接解析后的路径组成，任意一个变化都需要重新加载
+ */
+func (m *MConfig) version() string {
+	if len(m.layers) > 0 {
+		versions := make([]string, len(m.layers))
+		for i, layer := range m.layers {
+			versions[i] = layer.version()
 		}
-		// 查看是否是数组模式
-		index := -1
-		if elem[len(elem)-1] == ']' {
-			boundary := false
-			nums := bytes.NewBuffer([]byte{})
-			for i := len(elem) - 2; i >= 0; i-- {
-				if elem[i] == '[' {
-					// 取出真正的key
-					elem = elem[0:i]
-					var err error
-					index, err = strconv.Atoi(nums.String())
-					if err != nil {
-						return "", nil, InvalidKeyErr
-					}
-					if index < 0 {
-						return "", nil, InvalidSliceIndexErr
-					}
-					boundary = true
-					break
-				} else if elem[i] == ' ' {
-					continue
-				} else {
-					nums.WriteByte(elem[i])
-				}
-			}
-			if boundary == false {
-				return "", nil, InvalidKeyErr
+		return strings.Join(versions, ",")
+	}
+	return m.sum + "@" + m.target
+}
+
+/*
+ * 重新计算文件的版本，格式同version()
+ */
+func (m *MConfig) currentVersion() (string, error) {
+	if len(m.layers) > 0 {
+		versions := make([]string, len(m.layers))
+		for i, layer := range m.layers {
+			version, err := layer.currentVersion()
+			if err != nil {
+				return "", err
 			}
+			versions[i] = version
 		}
-		// 从rawMap中找到对应的项，所以这里要生成key字符串
-		elem = strings.Trim(elem, " ")
-		shapingKey.WriteString(elem)
-		if index >= 0 {
-			shapingKey.WriteString("[")
-			shapingKey.WriteString(strconv.Itoa(index))
-			shapingKey.WriteString("]")
+		return strings.Join(versions, ","), nil
+	}
+	target, err := filepath.EvalSymlinks(m.path)
+	if err != nil {
+		return "", err
+	}
+	if m.opts.hash == HashModTime && target == m.target {
+		stat, err := statFile(target)
+		if err != nil {
+			return "", err
 		}
-		if i != len(elem)-1 {
-			shapingKey.WriteString(".")
+		if stat == m.stat {
+			return m.version(), nil
 		}
+	}
+	sum, err := m.opts.hash.sum(target)
+	if err != nil {
+		return "", err
+	}
+	return sum + "@" + target, nil
+}
 
-		// 查看当前key的内容
-		if val2, ok := rawMap[elem]; ok {
-			// 如果已经遍历到最后一个elem，就直接调用处理函数进行处理
-			if i == len(elems)-1 {
-				value, err := lastElemHandler(val2, index)
-				return shapingKey.String(), value, err
-			} else {
-				// 检查是否是数组
-				if index >= 0 {
-					var rawSliceMap []json.RawMessage
-					if err := json.Unmarshal(val2, &rawSliceMap); err != nil {
-						return "", nil, err
-					} else {
-						// 下标不对
-						if len(rawSliceMap) <= index {
-							return "", nil, InvalidSliceIndexErr
-						}
-						// 解析失败
-						if err := json.Unmarshal(rawSliceMap[index], &rawMap); err != nil {
-							return "", nil, err
-						}
-					}
-				} else if err := json.Unmarshal(val2, &rawMap); err != nil {
-					return "", nil, err
-				}
-			}
-		} else {
-			// 如果key不存在，就直接返回失败
-			return "", nil, KeyNotFoundErr
+func (m *MConfig) checkFileDiff() bool {
+	version, err := m.currentVersion()
+	if err != nil {
+		log.Printf("can't get file:%s version, error:%s\n", m.path, err.Error())
+		return false
+	}
+	return version != m.version()
+}
+
+/*
+ * 重新从文件加载配置，返回新的MConfig
+ */
+func (m *MConfig) reload() (*MConfig, error) {
+	if len(m.layers) > 0 {
+		paths := make([]string, len(m.layers))
+		for i, layer := range m.layers {
+			paths[i] = layer.path
 		}
+		return newLayeredMConfig(paths, m.opts)
 	}
-	return "", nil, KeyNotFoundErr
+	return newMConfig(m.path, m.opts)
 }
 
-func (m *MConfig) GetString(key string) (string, error) {
-	val, ok := m.parsedEntryMap.Load(key)
-	if ok {
-		strVal, ok := val.(string)
-		if !ok {
-			return "", TypeErr
+/*
+ * 配置的值是否没有类型信息，分层配置中只要有一层没有类型就按照没有类型处理
+ */
+func (m *MConfig) untyped() bool {
+	if m.format.untyped() {
+		return true
+	}
+	for _, layer := range m.layers {
+		if layer.format.untyped() {
+			return true
 		}
-		return strVal, nil
-	} else {
-		shapingKey, val, err := m.travel(key, func(val json.RawMessage, index int) (interface{}, error) {
-			if index < 0 {
-				var strVal string
-				if err := json.Unmarshal(val, &strVal); err != nil {
-					return "", err
-				}
-				return strVal, nil
-			} else {
-				var strSliceVal []string
-				if err := json.Unmarshal(val, &strSliceVal); err != nil {
-					return "", err
-				}
-				if len(strSliceVal) <= index {
-					return "", InvalidSliceIndexErr
-				}
-				return strSliceVal[index], nil
+	}
+	return false
+}
+
+/*
+ * 在节点树中查找key对应的节点
+ * travel 支持按照字符串的模式遍历，例如传入key1.key2.key3的模式，特别针对数组的情况
+ * 你可以使用key1.key2[1].key3的模式传入，函数会帮你解析数组下标
+ */
+func (m *MConfig) travel(key string, lastElemHandler func(n *node, weak bool) (interface{}, error)) (interface{}, error) {
+	key, err := m.resolveKey(key)
+	if err != nil {
+		return nil, err
+	}
+	// 命令行参数和环境变量的优先级高于配置文件，值都是字符串，需要按照目标类型转换
+	if overlayVal, _, ok := m.lookupOverlay(key); ok {
+		return lastElemHandler(stringValueNode(overlayVal), true)
+	}
+	if m.root == nil {
+		return nil, KeyNotFoundErr
+	}
+
+	n, err := m.root.find(key)
+	if err != nil {
+		return nil, err
+	}
+	return lastElemHandler(n, m.untyped())
+}
+
+/*
+ * 在canonicalKey的基础上，把节点树中对应数组的数字key转换成下标
+ * 例如 /key10/key11/0/key12 会变成 key10.key11[0].key12，和其他写法共用缓存、命令行参数和环境变量
+ * 节点树中不存在的部分无法判断是否为数组，数字仍然按照对象的key处理
+ * 结果和节点树的结构有关，按照key缓存在当前的MConfig中，重新加载之后按照新的节点树重新解析，
+ * 例如数组变成对象之后 /list/0 对应list.0，之前通过FlagValue绑定的list[0]不再覆盖它
+ */
+func (m *MConfig) resolveKey(key string) (string, error) {
+	if isCanonical(key) {
+		return key, nil
+	}
+	if resolved, ok := m.resolvedKeys.Load(key); ok {
+		return resolved.(string), nil
+	}
+	path, err := ParseKey(key)
+	if err != nil {
+		return "", err
+	}
+	for i, n := 0, m.root; i < len(path) && n != nil; i++ {
+		if n.kind == arrayNode && !path[i].IsIndex {
+			if index, ok := arrayIndex(path[i].Key); ok {
+				path[i] = PathElem{Index: index, IsIndex: true}
 			}
-		})
-		if err != nil {
-			return "", err
-		} else {
-			m.parsedEntryMap.Store(shapingKey, val.(string))
-			return val.(string), nil
 		}
+		n, _ = n.child(path[i])
+	}
+	resolved := path.String()
+	m.resolvedKeys.Store(key, resolved)
+	return resolved, nil
+}
+
+/*
+ * 缓存的key，同一个配置项按照不同的类型读取时分别缓存
+ */
+type cacheKey struct {
+	path string
+	typ  ValueType
+}
+
+/*
+ * Get*的公共流程，key先规范化，写法不同的同一个key共用缓存
+ * 被命令行参数或者环境变量覆盖的key不走缓存，保证每次都读到最新的值
+ */
+func (m *MConfig) get(key string, typ ValueType, convert func(n *node, weak bool) (interface{}, error)) (interface{}, error) {
+	canonical, err := m.resolveKey(key)
+	if err != nil {
+		return nil, err
+	}
+	if _, _, ok := m.lookupOverlay(canonical); ok {
+		return m.travel(canonical, convert)
+	}
+	cached := cacheKey{path: canonical, typ: typ}
+	if val, ok := m.parsedEntryMap.Load(cached); ok {
+		return val, nil
+	}
+	val, err := m.travel(canonical, convert)
+	if err != nil {
+		return nil, err
+	}
+	m.parsedEntryMap.Store(cached, val)
+	return val, nil
+}
+
+func (m *MConfig) GetString(key string) (string, error) {
+	val, err := m.get(key, String, func(n *node, weak bool) (interface{}, error) {
+		return n.toString(weak)
+	})
+	if err != nil {
+		return "", err
 	}
+	return val.(string), nil
 }
 
 func (m *MConfig) GetStringSlice(key string) ([]string, error) {
-	val, ok := m.parsedEntryMap.Load(key)
-	if ok {
-		strSliceVal, ok := val.([]string)
-		if !ok {
-			return []string{}, TypeErr
-		}
-		return strSliceVal, nil
-	} else {
-		shapingKey, val, err := m.travel(key, func(val json.RawMessage, index int) (interface{}, error) {
-			var strSliceVal []string
-			if err := json.Unmarshal(val, &strSliceVal); err != nil {
-				return []string{}, err
-			}
-			return strSliceVal, nil
-		})
-		if err != nil {
-			return []string{}, err
-		} else {
-			m.parsedEntryMap.Store(shapingKey, val.([]string))
-			return val.([]string), nil
-		}
+	val, err := m.get(key, StringSlice, func(n *node, weak bool) (interface{}, error) {
+		return n.toStringSlice(weak)
+	})
+	if err != nil {
+		return []string{}, err
 	}
+	return val.([]string), nil
 }
 
 func (m *MConfig) GetStringWithDefault(key string, defaultVal string) string {
@@ -239,64 +348,23 @@ func (m *MConfig) GetStringWithDefault(key string, defaultVal string) string {
 }
 
 func (m *MConfig) GetInt(key string) (int, error) {
-	val, ok := m.parsedEntryMap.Load(key)
-	if ok {
-		intVal, ok := val.(int)
-		if !ok {
-			return 0, TypeErr
-		}
-		return intVal, nil
-	} else {
-		shapingKey, val, err := m.travel(key, func(val json.RawMessage, index int) (interface{}, error) {
-			if index < 0 {
-				var intVal int
-				if err := json.Unmarshal(val, &intVal); err != nil {
-					return 0, err
-				}
-				return intVal, nil
-			} else {
-				var intSliceVal []int
-				if err := json.Unmarshal(val, &intSliceVal); err != nil {
-					return 0, err
-				}
-				if len(intSliceVal) <= index {
-					return 0, InvalidSliceIndexErr
-				}
-				return intSliceVal[index], nil
-			}
-		})
-		if err != nil {
-			return 0, err
-		} else {
-			m.parsedEntryMap.Store(shapingKey, val.(int))
-			return val.(int), nil
-		}
+	val, err := m.get(key, Int, func(n *node, weak bool) (interface{}, error) {
+		return n.toInt(weak)
+	})
+	if err != nil {
+		return 0, err
 	}
+	return val.(int), nil
 }
 
 func (m *MConfig) GetIntSlice(key string) ([]int, error) {
-	val, ok := m.parsedEntryMap.Load(key)
-	if ok {
-		intSliceVal, ok := val.([]int)
-		if !ok {
-			return []int{}, TypeErr
-		}
-		return intSliceVal, nil
-	} else {
-		shapingKey, val, err := m.travel(key, func(val json.RawMessage, index int) (interface{}, error) {
-			var intSliceVal []int
-			if err := json.Unmarshal(val, &intSliceVal); err != nil {
-				return []int{}, err
-			}
-			return intSliceVal, nil
-		})
-		if err != nil {
-			return []int{}, err
-		} else {
-			m.parsedEntryMap.Store(shapingKey, val.([]int))
-			return val.([]int), nil
-		}
+	val, err := m.get(key, IntSlice, func(n *node, weak bool) (interface{}, error) {
+		return n.toIntSlice(weak)
+	})
+	if err != nil {
+		return []int{}, err
 	}
+	return val.([]int), nil
 }
 
 func (m *MConfig) GetIntWithDefault(key string, defaultVal int) int {
@@ -308,64 +376,23 @@ func (m *MConfig) GetIntWithDefault(key string, defaultVal int) int {
 }
 
 func (m *MConfig) GetFloat(key string) (float32, error) {
-	val, ok := m.parsedEntryMap.Load(key)
-	if ok {
-		floatVal, ok := val.(float32)
-		if !ok {
-			return 0.0, TypeErr
-		}
-		return floatVal, nil
-	} else {
-		shapingKey, val, err := m.travel(key, func(val json.RawMessage, index int) (interface{}, error) {
-			if index < 0 {
-				var floatVal float32
-				if err := json.Unmarshal(val, &floatVal); err != nil {
-					return 0.0, err
-				}
-				return floatVal, nil
-			} else {
-				var floatSliceVal []float32
-				if err := json.Unmarshal(val, &floatSliceVal); err != nil {
-					return 0, err
-				}
-				if len(floatSliceVal) <= index {
-					return 0, InvalidSliceIndexErr
-				}
-				return floatSliceVal[index], nil
-			}
-		})
-		if err != nil {
-			return 0.0, err
-		} else {
-			m.parsedEntryMap.Store(shapingKey, val.(float32))
-			return val.(float32), nil
-		}
+	val, err := m.get(key, Float, func(n *node, weak bool) (interface{}, error) {
+		return n.toFloat(weak)
+	})
+	if err != nil {
+		return 0.0, err
 	}
+	return val.(float32), nil
 }
 
 func (m *MConfig) GetFloatSlice(key string) ([]float32, error) {
-	val, ok := m.parsedEntryMap.Load(key)
-	if ok {
-		floatSliceVal, ok := val.([]float32)
-		if !ok {
-			return []float32{}, TypeErr
-		}
-		return floatSliceVal, nil
-	} else {
-		shapingKey, val, err := m.travel(key, func(val json.RawMessage, index int) (interface{}, error) {
-			var floatSliceVal []float32
-			if err := json.Unmarshal(val, &floatSliceVal); err != nil {
-				return []float32{}, err
-			}
-			return floatSliceVal, nil
-		})
-		if err != nil {
-			return []float32{}, err
-		} else {
-			m.parsedEntryMap.Store(shapingKey, val.([]float32))
-			return val.([]float32), nil
-		}
+	val, err := m.get(key, FloatSlice, func(n *node, weak bool) (interface{}, error) {
+		return n.toFloatSlice(weak)
+	})
+	if err != nil {
+		return []float32{}, err
 	}
+	return val.([]float32), nil
 }
 
 func (m *MConfig) GetFloatWithDefault(key string, defaultVal float32) float32 {
@@ -376,70 +403,216 @@ func (m *MConfig) GetFloatWithDefault(key string, defaultVal float32) float32 {
 	}
 }
 
+/*
+ * 获取64位整数，1e3这类值为整数的写法也可以获取，有小数部分时返回TypeErr，超出范围时返回OutOfRangeErr
+ */
+func (m *MConfig) GetInt64(key string) (int64, error) {
+	val, err := m.get(key, Int64, func(n *node, weak bool) (interface{}, error) {
+		return n.toInt64(weak)
+	})
+	if err != nil {
+		return 0, err
+	}
+	return val.(int64), nil
+}
+
+func (m *MConfig) GetInt64Slice(key string) ([]int64, error) {
+	val, err := m.get(key, Int64Slice, func(n *node, weak bool) (interface{}, error) {
+		return n.toInt64Slice(weak)
+	})
+	if err != nil {
+		return []int64{}, err
+	}
+	return val.([]int64), nil
+}
+
+func (m *MConfig) GetInt64WithDefault(key string, defaultVal int64) int64 {
+	if val, err := m.GetInt64(key); err != nil {
+		return defaultVal
+	} else {
+		return val
+	}
+}
+
+/*
+ * 获取64位无符号整数，规则和GetInt64相同，负数返回OutOfRangeErr
+ */
+func (m *MConfig) GetUint64(key string) (uint64, error) {
+	val, err := m.get(key, Uint64, func(n *node, weak bool) (interface{}, error) {
+		return n.toUint64(weak)
+	})
+	if err != nil {
+		return 0, err
+	}
+	return val.(uint64), nil
+}
+
+func (m *MConfig) GetUint64Slice(key string) ([]uint64, error) {
+	val, err := m.get(key, Uint64Slice, func(n *node, weak bool) (interface{}, error) {
+		return n.toUint64Slice(weak)
+	})
+	if err != nil {
+		return []uint64{}, err
+	}
+	return val.([]uint64), nil
+}
+
+func (m *MConfig) GetUint64WithDefault(key string, defaultVal uint64) uint64 {
+	if val, err := m.GetUint64(key); err != nil {
+		return defaultVal
+	} else {
+		return val
+	}
+}
+
+/*
+ * 获取64位浮点数，0.1不会因为float32的精度变成0.100000001
+ */
+func (m *MConfig) GetFloat64(key string) (float64, error) {
+	val, err := m.get(key, Float64, func(n *node, weak bool) (interface{}, error) {
+		return n.toFloat64(weak)
+	})
+	if err != nil {
+		return 0.0, err
+	}
+	return val.(float64), nil
+}
+
+func (m *MConfig) GetFloat64Slice(key string) ([]float64, error) {
+	val, err := m.get(key, Float64Slice, func(n *node, weak bool) (interface{}, error) {
+		return n.toFloat64Slice(weak)
+	})
+	if err != nil {
+		return []float64{}, err
+	}
+	return val.([]float64), nil
+}
+
+func (m *MConfig) GetFloat64WithDefault(key string, defaultVal float64) float64 {
+	if val, err := m.GetFloat64(key); err != nil {
+		return defaultVal
+	} else {
+		return val
+	}
+}
+
+/*
+ * 获取数字原始的写法，可以用于超出int64、float64精度的值，null返回空的json.Number
+ */
+func (m *MConfig) GetNumber(key string) (json.Number, error) {
+	val, err := m.get(key, Number, func(n *node, weak bool) (interface{}, error) {
+		return n.toNumber(weak)
+	})
+	if err != nil {
+		return "", err
+	}
+	return val.(json.Number), nil
+}
+
+func (m *MConfig) GetNumberSlice(key string) ([]json.Number, error) {
+	val, err := m.get(key, NumberSlice, func(n *node, weak bool) (interface{}, error) {
+		return n.toNumberSlice(weak)
+	})
+	if err != nil {
+		return []json.Number{}, err
+	}
+	return val.([]json.Number), nil
+}
+
+func (m *MConfig) GetNumberWithDefault(key string, defaultVal json.Number) json.Number {
+	if val, err := m.GetNumber(key); err != nil {
+		return defaultVal
+	} else {
+		return val
+	}
+}
+
 func (m *MConfig) GetBool(key string) (bool, error) {
-	val, ok := m.parsedEntryMap.Load(key)
-	if ok {
-		boolVal, ok := val.(bool)
-		if !ok {
-			return false, TypeErr
-		}
-		return boolVal, nil
+	val, err := m.get(key, Bool, func(n *node, weak bool) (interface{}, error) {
+		return n.toBool(weak)
+	})
+	if err != nil {
+		return false, err
+	}
+	return val.(bool), nil
+}
+
+func (m *MConfig) GetBoolSlice(key string) ([]bool, error) {
+	val, err := m.get(key, BoolSlice, func(n *node, weak bool) (interface{}, error) {
+		return n.toBoolSlice(weak)
+	})
+	if err != nil {
+		return []bool{}, err
+	}
+	return val.([]bool), nil
+}
+
+func (m *MConfig) GetBoolWithDefault(key string, defaultVal bool) bool {
+	if val, err := m.GetBool(key); err != nil {
+		return defaultVal
 	} else {
-		shapingKey, val, err := m.travel(key, func(val json.RawMessage, index int) (interface{}, error) {
-			if index < 0 {
-				var boolVal bool
-				if err := json.Unmarshal(val, &boolVal); err != nil {
-					return 0.0, err
-				}
-				return boolVal, nil
-			} else {
-				var boolSliceVal []bool
-				if err := json.Unmarshal(val, &boolSliceVal); err != nil {
-					return 0, err
-				}
-				if len(boolSliceVal) <= index {
-					return 0, InvalidSliceIndexErr
-				}
-				return boolSliceVal[index], nil
-			}
-		})
-		if err != nil {
-			return false, err
-		} else {
-			m.parsedEntryMap.Store(shapingKey, val.(bool))
-			return val.(bool), nil
+		return val
+	}
+}
+
+/*
+ * 把逗号分隔的字符串拆分成数组，字符串本身是json数组时按照json解析
+ */
+func splitWeakSlice(strVal string) []json.RawMessage {
+	var rawSliceVal []json.RawMessage
+	strVal = strings.TrimSpace(strVal)
+	if strings.HasPrefix(strVal, "[") && json.Unmarshal([]byte(strVal), &rawSliceVal) == nil {
+		return rawSliceVal
+	}
+	if strVal != "" {
+		for _, item := range strings.Split(strVal, ",") {
+			rawItem, _ := json.Marshal(strings.TrimSpace(item))
+			rawSliceVal = append(rawSliceVal, rawItem)
 		}
 	}
+	return rawSliceVal
 }
 
-func (m *MConfig) GetBoolSlice(key string) ([]bool, error) {
-	// 先从缓存中取出
-	val, ok := m.parsedEntryMap.Load(key)
-	if ok {
-		boolSliceVal, ok := val.([]bool)
-		if !ok {
-			return []bool{}, TypeErr
+/*
+ * 解析时间字符串，带时区的按照时区解析，不带时区的按照本地时间解析
+ */
+func parseTime(s string) (time.Time, error) {
+	for _, layout := range timeLayouts {
+		if t, err := time.ParseInLocation(layout, s, time.Local); err == nil {
+			return t, nil
 		}
-		return boolSliceVal, nil
-	} else {
-		shapingKey, val, err := m.travel(key, func(val json.RawMessage, index int) (interface{}, error) {
-			var boolSliceVal []bool
-			if err := json.Unmarshal(val, &boolSliceVal); err != nil {
-				return []bool{}, err
-			}
-			return boolSliceVal, nil
-		})
+	}
+	return time.Time{}, InvalidTimeErr
+}
+
+/*
+ * 获取时间类型的配置项，支持RFC3339以及toml的本地日期时间格式
+ * toml原生的日期时间按照它的种类解析，toml中的字符串不是时间，返回TypeErr
+ * 其他格式没有原生的日期时间，字符串按照timeLayouts解析，需要知道种类时使用GetDatetime
+ */
+func (m *MConfig) GetTime(key string) (time.Time, error) {
+	strict := m.hasDatetimes()
+	val, err := m.get(key, Time, func(n *node, weak bool) (interface{}, error) {
+		if n.datetime != 0 {
+			return n.datetime.parse(n.str)
+		}
+		if strict && !weak {
+			return time.Time{}, TypeErr
+		}
+		strVal, err := n.toString(weak)
 		if err != nil {
-			return []bool{}, err
-		} else {
-			m.parsedEntryMap.Store(shapingKey, val.([]bool))
-			return val.([]bool), nil
+			return time.Time{}, err
 		}
+		return parseTime(strVal)
+	})
+	if err != nil {
+		return time.Time{}, err
 	}
+	return val.(time.Time), nil
 }
 
-func (m *MConfig) GetBoolWithDefault(key string, defaultVal bool) bool {
-	if val, err := m.GetBool(key); err != nil {
+func (m *MConfig) GetTimeWithDefault(key string, defaultVal time.Time) time.Time {
+	if val, err := m.GetTime(key); err != nil {
 		return defaultVal
 	} else {
 		return val
@@ -448,14 +621,15 @@ func (m *MConfig) GetBoolWithDefault(key string, defaultVal bool) bool {
 
 /*
  * 返回原始片段，如果需要自己处理的话，可以自己处理
+ * 返回的是拷贝，修改它不会影响配置
  */
 func (m *MConfig) GetRawMessage(key string) (json.RawMessage, error) {
-	_, val, err := m.travel(key, func(val json.RawMessage, index int) (interface{}, error) {
-		var rawVal json.RawMessage
-		if err := json.Unmarshal(val, &rawVal); err != nil {
-			return json.RawMessage{}, err
+	val, err := m.travel(key, func(n *node, weak bool) (interface{}, error) {
+		if weak {
+			// 没有类型的配置中同时有值和子key的对象，不返回内部保存值的leafKey
+			return append(json.RawMessage(nil), stripLeaf(n.raw)...), nil
 		}
-		return rawVal, nil
+		return append(json.RawMessage(nil), n.raw...), nil
 	})
 	if err != nil {
 		return json.RawMessage{}, err
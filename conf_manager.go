@@ -2,8 +2,11 @@ package conf
 
 import (
 	"context"
+	"log"
 	"sync"
 	"time"
+
+	"github.com/xeipuuv/gojsonschema"
 )
 
 var configManager *MConfigManager
@@ -11,44 +14,264 @@ var emptyConfig *MConfig
 
 const monitorDuration = 10
 
+// 使用inotify时定期检查文件hash的默认间隔
+const safetyDuration = 5 * time.Minute
+
 type MConfigManager struct {
-	confs    sync.Map
-	ctx      context.Context
-	cancel   context.CancelFunc
-	callback map[string]func(string)
+	confs sync.Map
+	// SetConfig传入的回调，monitor中读取，需要加锁
+	callback     map[string]func(string)
+	callbackLock sync.RWMutex
+	// Bind绑定的值，配置更新后重新解析
+	bindings map[string][]binding
+	bindLock sync.Mutex
+	// 每个配置重新解析绑定的值时使用的锁，保证同一个配置的重新解析串行执行
+	rebindLocks map[string]*sync.Mutex
+	// OnChange订阅的key
+	subscriptions map[string][]subscription
+	subLock       sync.Mutex
+	// 每个配置的加载状态
+	status sync.Map
+	// NewManager或者StartMonitor传入的选项
+	opts managerOptions
+	// 监听的启动和停止，cancel不为空表示正在监听
+	runLock sync.Mutex
+	cancel  context.CancelFunc
+	done    chan struct{}
+	// 配置增加、替换或者删除之后通知monitor更新监听的文件
+	refresh chan struct{}
 }
 
 func init() {
-	ctx, cancel := context.WithCancel(context.Background())
-	configManager = &MConfigManager{
-		ctx:      ctx,
-		cancel:   cancel,
-		callback: make(map[string]func(string), 0),
+	configManager = NewManager()
+	emptyConfig, _ = newMConfig("", configOptions{})
+}
+
+/*
+ * 创建一个独立的配置管理器，和包级别的函数使用的默认管理器互不影响
+ * 适合在测试中隔离配置，或者在同一个进程中管理多组配置
+ */
+func NewManager(opts ...ManagerOption) *MConfigManager {
+	return &MConfigManager{
+		callback:      make(map[string]func(string), 0),
+		bindings:      make(map[string][]binding, 0),
+		rebindLocks:   make(map[string]*sync.Mutex, 0),
+		subscriptions: make(map[string][]subscription, 0),
+		opts:          newManagerOptions(opts),
+		refresh:       make(chan struct{}, 1),
+	}
+}
+
+// SetConfig的可选项
+type configOptions struct {
+	format Format
+	// 环境变量覆盖的前缀，为空时不启用
+	envPrefix string
+	// 绑定的命令行参数，重新加载时共用
+	flags *flagOverlay
+	// 分层配置中数组的合并方式
+	arrayMerge ArrayMergeMode
+	// 校验配置使用的JSON Schema
+	schemaSource []byte
+	schemaPath   string
+	schema       *gojsonschema.Schema
+	// 必需的配置项
+	required []Requirement
+	// 重新加载失败时的回调
+	errorCallback func(confName string, err error)
+	// 配置更新之后的回调，包含变化的key
+	changeCallback func(event ChangeEvent)
+	// 检查文件变化的算法
+	hash HashAlgorithm
+	// 为true时不监听文件变化
+	noWatch bool
+}
+
+type ConfigOption func(*configOptions)
+
+/*
+ * 显式指定配置文件的格式，不指定时根据扩展名判断
+ */
+func WithFormat(format Format) ConfigOption {
+	return func(o *configOptions) {
+		o.format = format
+	}
+}
+
+func newConfigOptions(opts []ConfigOption) (configOptions, error) {
+	options := configOptions{}
+	for _, opt := range opts {
+		opt(&options)
 	}
-	emptyConfig = newMConfig("")
+	return options, options.compileSchema()
 }
 
 /*
  * 设置配置文件名和路径信息
+ * 文件不存在、没有权限时返回*LoadError，解析失败时返回带行号的*ParseError，
+ * 校验失败时返回*SchemaError，缺少WithRequire声明的配置项时返回*RequirementError
+ */
+func SetConfig(confName string, fpath string, callback func(string), opts ...ConfigOption) error {
+	return configManager.SetConfig(confName, fpath, callback, opts...)
+}
+
+func (m *MConfigManager) SetConfig(confName string, fpath string, callback func(string), opts ...ConfigOption) error {
+	options, err := newConfigOptions(opts)
+	if err != nil {
+		return err
+	}
+	if _, ok := m.confs.Load(confName); ok == false {
+		conf, err := newMConfig(fpath, options)
+		if err != nil {
+			return err
+		}
+		if err := conf.validate(); err != nil {
+			return err
+		}
+		m.add(confName, conf, callback)
+	}
+	return nil
+}
+
+/*
+ * 设置分层配置，多个文件按照顺序深度合并成一个配置，后面的文件优先级更高
+ * 例如 SetLayeredConfig("default", []string{"base.json", "prod.json", "local.json"}, nil)
+ * 任意一层文件变化时都会重新合并
  */
-func SetConfig(confName string, fpath string, callback func(string)) error {
-	if _, ok := configManager.confs.Load(confName); ok == false {
-		conf := newMConfig(fpath)
-		if conf == nil {
-			return nil
+func SetLayeredConfig(confName string, fpaths []string, callback func(string), opts ...ConfigOption) error {
+	return configManager.SetLayeredConfig(confName, fpaths, callback, opts...)
+}
+
+func (m *MConfigManager) SetLayeredConfig(confName string, fpaths []string, callback func(string), opts ...ConfigOption) error {
+	options, err := newConfigOptions(opts)
+	if err != nil {
+		return err
+	}
+	if _, ok := m.confs.Load(confName); ok == false {
+		conf, err := newLayeredMConfig(fpaths, options)
+		if err != nil {
+			return err
 		}
-		configManager.confs.Store(confName, conf)
-		configManager.callback[confName] = callback
+		if err := conf.validate(); err != nil {
+			return err
+		}
+		m.add(confName, conf, callback)
+	}
+	return nil
+}
+
+/*
+ * 加入新的配置，同名的配置已经存在时不做任何事
+ */
+func (m *MConfigManager) add(confName string, conf *MConfig, callback func(string)) {
+	if _, loaded := m.confs.LoadOrStore(confName, conf); loaded {
+		return
+	}
+	m.setCallback(confName, callback)
+	m.loadSucceeded(confName, conf)
+	m.requestRefresh()
+}
+
+/*
+ * 替换配置confName，新的配置加载或者校验失败时返回错误，原来的配置不受影响
+ * 不再监听原来的文件，订阅者会收到新旧配置之间的变化，配置不存在时和SetConfig相同
+ * 绑定在原来配置上的命令行参数对新的配置同样生效
+ * SetConfig对已经存在的配置不做任何事，需要修改路径或者回调时使用ReplaceConfig
+ */
+func ReplaceConfig(confName string, fpath string, callback func(string), opts ...ConfigOption) error {
+	return configManager.ReplaceConfig(confName, fpath, callback, opts...)
+}
+
+func (m *MConfigManager) ReplaceConfig(confName string, fpath string, callback func(string), opts ...ConfigOption) error {
+	options, err := newConfigOptions(opts)
+	if err != nil {
+		return err
+	}
+	// 绑定在原来配置上的命令行参数继续生效，FlagSet上的参数不能重复注册
+	if old, ok := m.confs.Load(confName); ok {
+		options.flags = old.(*MConfig).opts.flags
+	}
+	conf, err := newMConfig(fpath, options)
+	if err != nil {
+		return err
 	}
+	if err := conf.validate(); err != nil {
+		return err
+	}
+	m.setCallback(confName, callback)
+	old, loaded := m.confs.Swap(confName, conf)
+	m.loadSucceeded(confName, conf)
+	m.requestRefresh()
+	if !loaded {
+		m.rebind(confName)
+		return nil
+	}
+	m.publish(newChangeEvent(confName, old.(*MConfig), conf))
 	return nil
 }
 
+/*
+ * 删除配置confName并停止监听它的文件，配置不存在时返回false
+ * 订阅者会收到所有key被删除的变化，绑定的值保留删除之前的内容
+ */
+func RemoveConfig(confName string) bool {
+	return configManager.RemoveConfig(confName)
+}
+
+func (m *MConfigManager) RemoveConfig(confName string) bool {
+	old, loaded := m.confs.LoadAndDelete(confName)
+	if !loaded {
+		return false
+	}
+	m.setCallback(confName, nil)
+	m.status.Delete(confName)
+	m.requestRefresh()
+	event := newChangeEvent(confName, old.(*MConfig), emptyConfig)
+	if !event.Empty() {
+		if event.Old.opts.changeCallback != nil {
+			event.Old.opts.changeCallback(event)
+		}
+		m.notifySubscriptions(event)
+	}
+	return true
+}
+
+func (m *MConfigManager) setCallback(confName string, callback func(string)) {
+	m.callbackLock.Lock()
+	defer m.callbackLock.Unlock()
+	if callback == nil {
+		delete(m.callback, confName)
+		return
+	}
+	m.callback[confName] = callback
+}
+
+func (m *MConfigManager) loadCallback(confName string) func(string) {
+	m.callbackLock.RLock()
+	defer m.callbackLock.RUnlock()
+	return m.callback[confName]
+}
+
+/*
+ * 通知monitor重新整理监听的文件，monitor没有运行时不阻塞
+ */
+func (m *MConfigManager) requestRefresh() {
+	select {
+	case m.refresh <- struct{}{}:
+	default:
+	}
+}
+
 /*
  * 获取默认的配置（针对只有一个配置文件的时候，简化操作)
  */
 func Config() *MConfig {
+	return configManager.Config()
+}
+
+func (m *MConfigManager) Config() *MConfig {
 	var conf *MConfig
-	configManager.confs.Range(func(key, value interface{}) bool {
+	m.confs.Range(func(key, value interface{}) bool {
 		conf = value.(*MConfig)
 		return false
 	})
@@ -63,7 +286,11 @@ func Config() *MConfig {
  */
 
 func MultiConfig(name string) *MConfig {
-	if val, ok := configManager.confs.Load(name); ok {
+	return configManager.MultiConfig(name)
+}
+
+func (m *MConfigManager) MultiConfig(name string) *MConfig {
+	if val, ok := m.confs.Load(name); ok {
 		return val.(*MConfig)
 	}
 	return emptyConfig
@@ -73,39 +300,220 @@ func MultiConfig(name string) *MConfig {
  * 关闭监听
  */
 func StopMonitor() {
-	configManager.cancel()
+	configManager.Stop()
+}
+
+/*
+ * 启动监听，可以指定轮询的间隔以及随机增加的时间，例如
+ * StartMonitor(WithInterval(500*time.Millisecond), WithJitter(100*time.Millisecond))
+ */
+func StartMonitor(opts ...ManagerOption) {
+	if len(opts) > 0 {
+		configManager.runLock.Lock()
+		configManager.opts = newManagerOptions(opts)
+		configManager.runLock.Unlock()
+	}
+	configManager.Start()
+}
+
+/*
+ * 启动监听，已经在监听时不做任何事，Stop之后可以再次Start
+ */
+func (m *MConfigManager) Start() {
+	m.runLock.Lock()
+	defer m.runLock.Unlock()
+	if m.cancel != nil {
+		return
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	m.cancel = cancel
+	m.done = done
+	go func(opts managerOptions) {
+		defer close(done)
+		m.monitor(ctx, opts)
+	}(m.opts)
+}
+
+/*
+ * 停止监听，等待正在进行的检查结束后返回
+ */
+func (m *MConfigManager) Stop() {
+	m.runLock.Lock()
+	defer m.runLock.Unlock()
+	if m.cancel == nil {
+		return
+	}
+	m.cancel()
+	<-m.done
+	m.cancel = nil
+	m.done = nil
 }
 
-func StartMonitor() {
-	go configManager.monitor()
+/*
+ * 优先使用inotify监听文件变化，文件变化后立即重新加载
+ * 创建监听失败时退回到按照opts.interval轮询文件的hash
+ */
+func (m *MConfigManager) monitor(ctx context.Context, opts managerOptions) {
+	watcher, err := newFileWatcher()
+	if err != nil {
+		log.Printf("can't create file watcher, fall back to polling, error:%s\n", err.Error())
+		m.watchLoop(ctx, opts, nil)
+		return
+	}
+	defer watcher.close()
+	m.watchLoop(ctx, opts, watcher)
 }
 
-func (m *MConfigManager) monitor() {
+/*
+ * watcher为nil或者有文件无法监听时按照opts.interval轮询，
+ * 所有文件都在监听时也按照opts.safetyInterval检查一次，防止文件系统不发送事件
+ */
+func (m *MConfigManager) watchLoop(ctx context.Context, opts managerOptions, watcher fileWatcher) {
+	// 当前监听的所有文件，配置替换或者删除之后用来取消不再需要的监听
+	watched := make(map[string]bool, 0)
+	polling, _ := m.watchFiles(watcher, watched)
+	// 开始监听之前发生的变化收不到事件，先检查一次
+	m.checkUpdate()
+	lastCheck := time.Now()
+	var changed <-chan struct{}
+	if watcher != nil {
+		changed = watcher.events()
+	}
+	timer := time.NewTimer(opts.nextInterval())
+	defer timer.Stop()
 	for {
 		select {
-		case <-m.ctx.Done():
+		case <-ctx.Done():
 			return
-		case <-time.After(monitorDuration * time.Second):
-			updatedConfs := sync.Map{}
-			// 检查所有的配置，查看是否有变化
-			m.confs.Range(func(key, value interface{}) bool {
-				conf := value.(*MConfig)
-				if conf.checkFileDiff() {
-					updatedConf := newMConfig(conf.path)
-					updatedConfs.Store(key, updatedConf)
-				}
-				return true
-			})
-			// 更新变化的配置（直接替换)
-			updatedConfs.Range(func(key, value interface{}) bool {
-				m.confs.Store(key, value)
-				if handler, ok := m.callback[key.(string)]; ok {
-					if handler != nil {
-						handler(key.(string))
-					}
-				}
+		case <-changed:
+			m.checkUpdate()
+			lastCheck = time.Now()
+			// 符号链接替换之后指向了新的文件，需要重新监听
+			m.watchFiles(watcher, watched)
+		case <-m.refresh:
+			// 新增的配置需要检查一次监听开始之前的变化
+			var added bool
+			polling, added = m.watchFiles(watcher, watched)
+			if added {
+				m.checkUpdate()
+				lastCheck = time.Now()
+			}
+		case <-timer.C:
+			var added bool
+			polling, added = m.watchFiles(watcher, watched)
+			if polling || added || time.Since(lastCheck) >= opts.safetyInterval {
+				m.checkUpdate()
+				lastCheck = time.Now()
+			}
+			timer.Reset(opts.nextInterval())
+		}
+	}
+}
+
+/*
+ * 把所有配置的文件加入监听，并取消已经不属于任何配置的文件的监听，watched为当前监听的文件
+ * 有文件无法监听时polling返回true，需要轮询，有新加入监听的文件时added返回true
+ */
+func (m *MConfigManager) watchFiles(watcher fileWatcher, watched map[string]bool) (polling bool, added bool) {
+	if watcher == nil {
+		return true, false
+	}
+	current := make(map[string]bool, len(watched))
+	m.confs.Range(func(key, value interface{}) bool {
+		if value.(*MConfig).opts.noWatch {
+			return true
+		}
+		for _, path := range value.(*MConfig).files() {
+			newlyAdded, err := watchFile(watcher, path, current)
+			if err != nil {
+				log.Printf("can't watch config:%s file:%s, fall back to polling, error:%s\n", key.(string), path, err.Error())
+				polling = true
+				continue
+			}
+			added = added || newlyAdded
+		}
+		return true
+	})
+	for path := range watched {
+		if current[path] {
+			continue
+		}
+		if err := watcher.unwatch(path); err != nil {
+			log.Printf("can't unwatch file:%s, error:%s\n", path, err.Error())
+		}
+		delete(watched, path)
+	}
+	for path := range current {
+		watched[path] = true
+	}
+	return polling, added
+}
+
+/*
+ * 检查所有的配置，重新加载发生变化的配置
+ */
+func (m *MConfigManager) checkUpdate() {
+	updatedConfs := sync.Map{}
+	// 检查所有的配置，查看是否有变化
+	m.confs.Range(func(key, value interface{}) bool {
+		confName := key.(string)
+		conf := value.(*MConfig)
+		if conf.opts.noWatch {
+			return true
+		}
+		version, err := conf.currentVersion()
+		if err != nil {
+			version = "error:" + err.Error()
+		}
+		if version == conf.version() {
+			return true
+		}
+		// 这个版本的文件已经加载失败过，等文件再次变化
+		if val, ok := m.status.Load(confName); ok && val.(ConfigStatus).failedVersion == version {
+			return true
+		}
+		// 读取、解析或者校验失败的配置不生效，继续使用原来的配置
+		if err == nil {
+			var updatedConf *MConfig
+			updatedConf, err = conf.reload()
+			if err == nil {
+				err = updatedConf.validate()
+			}
+			if err == nil {
+				updatedConfs.Store(key, newChangeEvent(confName, conf, updatedConf))
 				return true
-			})
+			}
+		}
+		log.Printf("reload config:%s failed, keep the old config, error:%s\n", confName, err.Error())
+		m.loadFailed(confName, conf, version, err)
+		return true
+	})
+	// 更新变化的配置（直接替换)
+	updatedConfs.Range(func(key, value interface{}) bool {
+		event := value.(ChangeEvent)
+		// 检查的过程中配置被替换或者删除了，放弃这次重新加载的结果
+		if !m.confs.CompareAndSwap(key, event.Old, event.New) {
+			return true
+		}
+		m.loadSucceeded(event.Name, event.New)
+		m.publish(event)
+		return true
+	})
+}
+
+/*
+ * 配置更新之后通知绑定的值、回调以及订阅者
+ */
+func (m *MConfigManager) publish(event ChangeEvent) {
+	m.rebind(event.Name)
+	if handler := m.loadCallback(event.Name); handler != nil {
+		handler(event.Name)
+	}
+	if !event.Empty() {
+		if event.New.opts.changeCallback != nil {
+			event.New.opts.changeCallback(event)
 		}
+		m.notifySubscriptions(event)
 	}
 }
@@ -1,8 +1,8 @@
 package conf
 
 import (
+	"conf/fileutil"
 	"fmt"
-	"meili_conf/fileutil"
 	"testing"
 	"time"
 )
@@ -0,0 +1,154 @@
+package conf
+
+import (
+	"time"
+)
+
+// toml原生日期时间的种类，零值表示不是原生的日期时间
+type DatetimeKind int
+
+const (
+	// 带时区的日期时间，例如1979-05-27T07:32:00-08:00
+	OffsetDatetime DatetimeKind = iota + 1
+	// 本地日期时间，例如1979-05-27T07:32:00
+	LocalDatetime
+	// 本地日期，例如1979-05-27
+	LocalDate
+	// 本地时间，例如07:32:00
+	LocalTime
+)
+
+func (k DatetimeKind) String() string {
+	switch k {
+	case OffsetDatetime:
+		return "offset-datetime"
+	case LocalDatetime:
+		return "local-datetime"
+	case LocalDate:
+		return "local-date"
+	case LocalTime:
+		return "local-time"
+	}
+	return "none"
+}
+
+func (k DatetimeKind) layout() string {
+	switch k {
+	case LocalDatetime:
+		return localDatetimeLayout
+	case LocalDate:
+		return localDateLayout
+	case LocalTime:
+		return localTimeLayout
+	}
+	return time.RFC3339Nano
+}
+
+/*
+ * 按照种类对应的格式解析，本地的日期时间使用time.Local
+ */
+func (k DatetimeKind) parse(s string) (time.Time, error) {
+	t, err := time.ParseInLocation(k.layout(), s, time.Local)
+	if err != nil {
+		return time.Time{}, InvalidTimeErr
+	}
+	return t, nil
+}
+
+/*
+ * 按照timeLayouts解析字符串，同时返回对应的种类，命令行参数和环境变量这类没有类型的值使用
+ */
+func parseDatetime(s string) (time.Time, DatetimeKind, error) {
+	for _, kind := range []DatetimeKind{OffsetDatetime, LocalDatetime, LocalDate, LocalTime} {
+		if t, err := kind.parse(s); err == nil {
+			return t, kind, nil
+		}
+	}
+	if t, err := time.ParseInLocation("2006-01-02 15:04:05.999999999", s, time.Local); err == nil {
+		return t, LocalDatetime, nil
+	}
+	return time.Time{}, 0, InvalidTimeErr
+}
+
+/*
+ * 把解码时记录的原生日期时间标记到节点树上，key为规范写法的完整key
+ */
+func markDatetimes(root *node, datetimes map[string]DatetimeKind) {
+	for key, kind := range datetimes {
+		if n, err := root.find(key); err == nil && n.kind == stringNode {
+			n.datetime = kind
+		}
+	}
+}
+
+/*
+ * 分层配置中，key是否为原生的日期时间以定义这个key的最高一层为准
+ * 数组追加合并之后下标可能变化，合并后的值和这一层不同时不标记
+ */
+func markLayerDatetimes(root *node, layers []*MConfig) {
+	for i, layer := range layers {
+		for key, kind := range layer.datetimes {
+			overridden := false
+			for _, upper := range layers[i+1:] {
+				if _, err := upper.root.find(key); err == nil {
+					overridden = true
+					break
+				}
+			}
+			if overridden {
+				continue
+			}
+			n, err := root.find(key)
+			if err != nil || n.kind != stringNode {
+				continue
+			}
+			if own, err := layer.root.find(key); err == nil && own.str == n.str {
+				n.datetime = kind
+			}
+		}
+	}
+}
+
+/*
+ * 配置中的日期时间是否都有类型，只有toml有原生的日期时间
+ * 这时字符串不会被当成时间，分层配置中混合了其他格式时仍然解析字符串
+ */
+func (m *MConfig) hasDatetimes() bool {
+	if len(m.layers) == 0 {
+		return m.format == TOMLFormat
+	}
+	for _, layer := range m.layers {
+		if layer.format != TOMLFormat {
+			return false
+		}
+	}
+	return true
+}
+
+type datetimeValue struct {
+	t    time.Time
+	kind DatetimeKind
+}
+
+/*
+ * 获取toml原生的日期时间以及它的种类，可以区分1979-05-27T07:32:00Z和字符串"1979-05-27T07:32:00Z"
+ * 字符串返回TypeErr，命令行参数和环境变量的值按照GetTime支持的格式解析
+ */
+func (m *MConfig) GetDatetime(key string) (time.Time, DatetimeKind, error) {
+	val, err := m.get(key, Datetime, func(n *node, weak bool) (interface{}, error) {
+		if n.datetime != 0 {
+			t, err := n.datetime.parse(n.str)
+			return datetimeValue{t: t, kind: n.datetime}, err
+		}
+		if !weak || n.leaf(weak).kind != stringNode {
+			return nil, TypeErr
+		}
+		t, kind, err := parseDatetime(n.leaf(weak).str)
+		return datetimeValue{t: t, kind: kind}, err
+	})
+	if err != nil {
+		return time.Time{}, 0, err
+	}
+	dt := val.(datetimeValue)
+	return dt.t, dt.kind, nil
+}
@@ -0,0 +1,128 @@
+package conf
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"regexp"
+	"strconv"
+
+	"github.com/BurntSushi/toml"
+)
+
+var ConfigNotFoundErr = errors.New("config file not found")
+var PermissionDeniedErr = errors.New("config file permission denied")
+
+/*
+ * 读取配置文件失败，Err为原始的错误
+ * 可以用errors.Is(err, ConfigNotFoundErr)、errors.Is(err, PermissionDeniedErr)判断原因
+ */
+type LoadError struct {
+	Path string
+	Err  error
+}
+
+func (e *LoadError) Error() string {
+	return fmt.Sprintf("load config file:%s failed, error:%s", e.Path, e.Err.Error())
+}
+
+func (e *LoadError) Unwrap() error {
+	return e.Err
+}
+
+func (e *LoadError) Is(target error) bool {
+	switch target {
+	case ConfigNotFoundErr:
+		return errors.Is(e.Err, fs.ErrNotExist)
+	case PermissionDeniedErr:
+		return errors.Is(e.Err, fs.ErrPermission)
+	}
+	return false
+}
+
+/*
+ * 解析配置文件失败，Line和Column从1开始，无法确定位置时为0
+ */
+type ParseError struct {
+	Path   string
+	Format Format
+	Line   int
+	Column int
+	Err    error
+}
+
+func (e *ParseError) Error() string {
+	pos := ""
+	if e.Line > 0 {
+		pos = fmt.Sprintf(" line %d", e.Line)
+		if e.Column > 0 {
+			pos += fmt.Sprintf(" column %d", e.Column)
+		}
+	}
+	return fmt.Sprintf("decode %s config file:%s failed at%s, error:%s", e.Format, e.Path, pos, e.Err.Error())
+}
+
+func (e *ParseError) Unwrap() error {
+	return e.Err
+}
+
+// yaml的错误只在信息中包含行号
+var yamlLineRegexp = regexp.MustCompile(`line (\d+)`)
+
+/*
+ * 按照解析器返回的错误确定出错的位置
+ */
+func newParseError(p string, format Format, content []byte, err error) *ParseError {
+	parseErr := &ParseError{Path: p, Format: format, Err: err}
+	var lineErr *ParseError
+	var syntaxErr *json.SyntaxError
+	var typeErr *json.UnmarshalTypeError
+	var tomlErr toml.ParseError
+	switch {
+	case errors.As(err, &lineErr):
+		// ini、properties、dotenv的解析器直接返回行号
+		parseErr.Line, parseErr.Column, parseErr.Err = lineErr.Line, lineErr.Column, lineErr.Err
+	case errors.As(err, &syntaxErr):
+		parseErr.Line, parseErr.Column = lineColumn(content, syntaxErr.Offset)
+	case errors.As(err, &typeErr):
+		parseErr.Line, parseErr.Column = lineColumn(content, typeErr.Offset)
+	case errors.As(err, &tomlErr):
+		parseErr.Line, parseErr.Column = tomlErr.Position.Line, tomlErr.Position.Col
+	case format == YAMLFormat:
+		if match := yamlLineRegexp.FindStringSubmatch(err.Error()); match != nil {
+			parseErr.Line, _ = strconv.Atoi(match[1])
+		}
+	}
+	return parseErr
+}
+
+/*
+ * 把json解析器给出的偏移量转换成行号和列号，偏移量是已经读取的字节数，出错的字符是最后一个
+ */
+func lineColumn(content []byte, offset int64) (int, int) {
+	if offset > int64(len(content)) {
+		offset = int64(len(content))
+	}
+	if offset <= 0 {
+		return 1, 1
+	}
+	before := content[:offset]
+	line := bytes.Count(before, []byte("\n")) + 1
+	column := len(before) - bytes.LastIndexByte(before, '\n') - 1
+	// 出错的字符正好是换行符时，位置算在上一行的末尾
+	if column == 0 {
+		line--
+		prev := before[:len(before)-1]
+		column = len(prev) - bytes.LastIndexByte(prev, '\n')
+	}
+	return line, column
+}
+
+/*
+ * key=value格式的解析器出错时带上行号
+ */
+func lineError(line int, err error) error {
+	return &ParseError{Line: line, Err: err}
+}
@@ -0,0 +1,74 @@
+package conf
+
+import (
+	"conf/fileutil"
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestLoadError(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "not_exist.json")
+	err := SetConfig("load_error", path, nil)
+	var loadErr *LoadError
+	if !errors.As(err, &loadErr) || loadErr.Path != path {
+		t.Fatalf("SetConfig(%s) error = %+v; expected *LoadError", path, err)
+	}
+	if !errors.Is(err, ConfigNotFoundErr) || !errors.Is(err, fs.ErrNotExist) || errors.Is(err, PermissionDeniedErr) {
+		t.Errorf("SetConfig(%s) error = %+v; expected not found", path, err)
+	}
+	if MultiConfig("load_error") != emptyConfig {
+		t.Errorf("failed config should not be stored")
+	}
+
+	if err := SetConfig("load_error", dir, nil); !errors.As(err, &loadErr) {
+		t.Errorf("SetConfig(%s) error = %+v; expected *LoadError for a directory", dir, err)
+	}
+
+	if os.Geteuid() == 0 {
+		return
+	}
+	path = filepath.Join(dir, "denied.json")
+	fileutil.WriteContent(path, `{}`)
+	os.Chmod(path, 0)
+	if err := SetConfig("load_error", path, nil); !errors.Is(err, PermissionDeniedErr) {
+		t.Errorf("SetConfig(%s) error = %+v; expected permission denied", path, err)
+	}
+}
+
+func TestParseError(t *testing.T) {
+	dir := t.TempDir()
+	cases := []struct {
+		name    string
+		content string
+		line    int
+		column  int
+	}{
+		{"syntax.json", "{\n  \"key1\": 1,\n  \"key2\": }\n", 3, 11},
+		{"type.json", "[1, 2]", 1, 1},
+		{"eof.json", "{\n  \"key1\": 1,\n", 2, 13},
+		{"bad.toml", "key1 = 1\nkey2 = \n", 2, 8},
+		{"bad.yaml", "key1: 1\nkey2: 2\n  key3: 3\n", 3, 0},
+		{"bad.ini", "[db]\nhost=localhost\nbroken line\n", 3, 0},
+		{"bad.properties", "a=1\nb=\\u12zz\n", 2, 0},
+	}
+	for _, c := range cases {
+		path := filepath.Join(dir, c.name)
+		fileutil.WriteContent(path, c.content)
+		err := SetConfig("parse_error", path, nil)
+		var parseErr *ParseError
+		if !errors.As(err, &parseErr) {
+			t.Errorf("SetConfig(%s) error = %+v; expected *ParseError", c.name, err)
+			continue
+		}
+		if parseErr.Path != path || parseErr.Line != c.line || parseErr.Column != c.column {
+			t.Errorf("SetConfig(%s) error at %d:%d; expected %d:%d, error:%+v", c.name, parseErr.Line, parseErr.Column, c.line, c.column, err)
+		}
+		if parseErr.Err == nil || errors.As(parseErr.Err, new(*ParseError)) {
+			t.Errorf("SetConfig(%s) error = %+v; expected the cause without nested ParseError", c.name, err)
+		}
+	}
+}
@@ -0,0 +1,165 @@
+package conf
+
+import (
+	"bytes"
+	"encoding/json"
+	"sort"
+	"strings"
+)
+
+/*
+ * 配置重新加载之后的变化
+ * Added、Removed、Modified为发生变化的key，格式和Get*方法的key相同，例如key10.key11[0].key12
+ * 整个对象或者数组元素新增、删除时只包含这个对象的key，不再展开其中的子key
+ * 只比较文件中的内容，环境变量和命令行参数的覆盖不参与比较
+ */
+type ChangeEvent struct {
+	Name     string
+	Old      *MConfig
+	New      *MConfig
+	Added    []string
+	Removed  []string
+	Modified []string
+}
+
+/*
+ * key本身、它的子key或者它的上级发生变化时返回true
+ */
+func (e ChangeEvent) Changed(key string) bool {
+	for _, keys := range [][]string{e.Added, e.Removed, e.Modified} {
+		for _, changed := range keys {
+			if keyContains(key, changed) || keyContains(changed, key) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+/*
+ * 没有任何key发生变化，例如只是修改了格式或者注释
+ */
+func (e ChangeEvent) Empty() bool {
+	return len(e.Added) == 0 && len(e.Removed) == 0 && len(e.Modified) == 0
+}
+
+/*
+ * sub是否等于parent或者在parent的下级
+ */
+func keyContains(parent string, sub string) bool {
+	if parent == "" || parent == sub {
+		return true
+	}
+	if !strings.HasPrefix(sub, parent) {
+		return false
+	}
+	next := sub[len(parent)]
+	return next == '.' || next == '['
+}
+
+/*
+ * 配置更新之后的回调，可以只处理关心的key，例如
+ * WithChangeCallback(func(e ChangeEvent) { if e.Changed("db") { ... } })
+ * 文件变化但是没有key变化时不调用
+ */
+func WithChangeCallback(callback func(event ChangeEvent)) ConfigOption {
+	return func(o *configOptions) {
+		o.changeCallback = callback
+	}
+}
+
+/*
+ * 比较两个配置，得到变化的key
+ */
+func newChangeEvent(name string, oldConf *MConfig, newConf *MConfig) ChangeEvent {
+	event := ChangeEvent{Name: name, Old: oldConf, New: newConf}
+	diffRawMap(&event, "", oldConf.rawEntryMap, newConf.rawEntryMap, oldConf.untyped() || newConf.untyped())
+	sort.Strings(event.Added)
+	sort.Strings(event.Removed)
+	sort.Strings(event.Modified)
+	return event
+}
+
+/*
+ * weak为true时，同时有值和子key的对象中保存值的leafKey按照对象本身的key报告
+ */
+func diffRawMap(event *ChangeEvent, path string, oldMap map[string]json.RawMessage, newMap map[string]json.RawMessage, weak bool) {
+	keyPath := func(key string) string {
+		if weak && key == leafKey && path != "" {
+			return path
+		}
+		return joinPath(path, key)
+	}
+	for key, oldVal := range oldMap {
+		if newVal, ok := newMap[key]; ok {
+			diffRaw(event, keyPath(key), oldVal, newVal, weak)
+		} else {
+			event.Removed = append(event.Removed, keyPath(key))
+		}
+	}
+	for key := range newMap {
+		if _, ok := oldMap[key]; !ok {
+			event.Added = append(event.Added, keyPath(key))
+		}
+	}
+}
+
+/*
+ * 两边都是对象或者都是数组时逐项比较，其他情况比较值本身
+ * weak为true时，值变成同时有值和子key的对象(或者反过来)，值按照对象中的leafKey比较
+ */
+func diffRaw(event *ChangeEvent, path string, oldVal json.RawMessage, newVal json.RawMessage, weak bool) {
+	oldKind, newKind := rawKind(oldVal), rawKind(newVal)
+	if weak && (oldKind == '{') != (newKind == '{') && oldKind != '[' && newKind != '[' {
+		if oldKind == '{' {
+			newVal, newKind = wrapLeaf(newVal), '{'
+		} else {
+			oldVal, oldKind = wrapLeaf(oldVal), '{'
+		}
+	}
+	if oldKind == '{' && newKind == '{' {
+		var oldMap, newMap map[string]json.RawMessage
+		if json.Unmarshal(oldVal, &oldMap) == nil && json.Unmarshal(newVal, &newMap) == nil {
+			diffRawMap(event, path, oldMap, newMap, weak)
+			return
+		}
+	}
+	if oldKind == '[' && newKind == '[' {
+		var oldSlice, newSlice []json.RawMessage
+		if json.Unmarshal(oldVal, &oldSlice) == nil && json.Unmarshal(newVal, &newSlice) == nil {
+			for i := 0; i < len(oldSlice) || i < len(newSlice); i++ {
+				switch {
+				case i >= len(newSlice):
+					event.Removed = append(event.Removed, indexPath(path, i))
+				case i >= len(oldSlice):
+					event.Added = append(event.Added, indexPath(path, i))
+				default:
+					diffRaw(event, indexPath(path, i), oldSlice[i], newSlice[i], weak)
+				}
+			}
+			return
+		}
+	}
+	if !rawEqual(oldVal, newVal) {
+		event.Modified = append(event.Modified, path)
+	}
+}
+
+func wrapLeaf(raw json.RawMessage) json.RawMessage {
+	wrapped, _ := json.Marshal(map[string]json.RawMessage{leafKey: raw})
+	return wrapped
+}
+
+/*
+ * 忽略空白比较两个json片段
+ */
+func rawEqual(a json.RawMessage, b json.RawMessage) bool {
+	if bytes.Equal(a, b) {
+		return true
+	}
+	var bufA, bufB bytes.Buffer
+	if json.Compact(&bufA, a) != nil || json.Compact(&bufB, b) != nil {
+		return false
+	}
+	return bytes.Equal(bufA.Bytes(), bufB.Bytes())
+}
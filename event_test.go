@@ -0,0 +1,100 @@
+package conf
+
+import (
+	"conf/fileutil"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestChangeEvent(t *testing.T) {
+	dir := t.TempDir()
+	oldPath := filepath.Join(dir, "old.json")
+	newPath := filepath.Join(dir, "new.yaml")
+	fileutil.WriteContent(oldPath, `{
+		"db": {"host": "127.0.0.1", "port": 3306, "pool": {"size": 10}},
+		"servers": [{"name": "a"}, {"name": "b"}],
+		"debug": true,
+		"same": [1, 2]
+	}`)
+	fileutil.WriteContent(newPath, `
+db:
+  host: 10.0.0.1
+  port: 3306
+  pool:
+    size: 10
+    idle: 2
+servers:
+  - name: a
+same: [1, 2]
+log: info
+`)
+	oldConf, err := newMConfig(oldPath, configOptions{})
+	if err != nil {
+		t.Fatalf("newMConfig error:%+v", err)
+	}
+	newConf, err := newMConfig(newPath, configOptions{})
+	if err != nil {
+		t.Fatalf("newMConfig error:%+v", err)
+	}
+	event := newChangeEvent("event", oldConf, newConf)
+	if expected := []string{"db.pool.idle", "log"}; !reflect.DeepEqual(event.Added, expected) {
+		t.Errorf("Added = %v; expected %v", event.Added, expected)
+	}
+	if expected := []string{"debug", "servers[1]"}; !reflect.DeepEqual(event.Removed, expected) {
+		t.Errorf("Removed = %v; expected %v", event.Removed, expected)
+	}
+	if expected := []string{"db.host"}; !reflect.DeepEqual(event.Modified, expected) {
+		t.Errorf("Modified = %v; expected %v", event.Modified, expected)
+	}
+
+	changed := map[string]bool{
+		"db":              true,
+		"db.pool":         true,
+		"db.host":         true,
+		"db.port":         false,
+		"db.hostname":     false,
+		"servers":         true,
+		"servers[0]":      false,
+		"servers[1].name": true,
+		"same":            false,
+	}
+	for key, expected := range changed {
+		if event.Changed(key) != expected {
+			t.Errorf("Changed(%s) = %t; expected %t", key, !expected, expected)
+		}
+	}
+}
+
+func TestChangeCallback(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "event.json")
+	fileutil.WriteContent(path, `{"key1": 1, "key2": 2}`)
+	var events []ChangeEvent
+	m := NewManager()
+	err := m.SetConfig("event", path, nil, WithChangeCallback(func(event ChangeEvent) {
+		events = append(events, event)
+	}))
+	if err != nil {
+		t.Fatalf("SetConfig error:%+v", err)
+	}
+	oldConf := m.MultiConfig("event")
+
+	// 只修改格式时不通知
+	fileutil.WriteContent(path, `{"key1": 1,   "key2": 2}`)
+	m.checkUpdate()
+	if len(events) != 0 {
+		t.Errorf("events = %+v; expected no event without key changes", events)
+	}
+
+	fileutil.WriteContent(path, `{"key1": 1, "key2": 3}`)
+	m.checkUpdate()
+	if len(events) != 1 {
+		t.Fatalf("events = %+v; expected one event", events)
+	}
+	if events[0].Name != "event" || events[0].New != m.MultiConfig("event") || events[0].Old == oldConf {
+		t.Errorf("event = %+v; expected old and new config of the last reload", events[0])
+	}
+	if !reflect.DeepEqual(events[0].Modified, []string{"key2"}) {
+		t.Errorf("Modified = %v; expected [key2]", events[0].Modified)
+	}
+}
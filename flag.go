@@ -0,0 +1,159 @@
+package conf
+
+import (
+	"encoding/json"
+	"flag"
+	"sync"
+)
+
+/*
+ * 绑定到配置项上的命令行参数
+ * 同时实现了flag.Value和pflag.Value(多了Type方法)，可以注册到任意一种FlagSet上
+ * 只有命令行上显式设置过的参数才会覆盖配置
+ */
+type FlagValue struct {
+	lock  sync.RWMutex
+	def   string
+	value string
+	set   bool
+}
+
+func (f *FlagValue) String() string {
+	if f == nil {
+		return ""
+	}
+	f.lock.RLock()
+	defer f.lock.RUnlock()
+	if f.set {
+		return f.value
+	}
+	return f.def
+}
+
+func (f *FlagValue) Set(s string) error {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	f.value = s
+	f.set = true
+	return nil
+}
+
+func (f *FlagValue) Type() string {
+	return "string"
+}
+
+/*
+ * 参数是否在命令行上显式设置过
+ */
+func (f *FlagValue) IsSet() bool {
+	f.lock.RLock()
+	defer f.lock.RUnlock()
+	return f.set
+}
+
+func (f *FlagValue) get() (string, bool) {
+	f.lock.RLock()
+	defer f.lock.RUnlock()
+	return f.value, f.set
+}
+
+/*
+ * 配置的所有参数绑定，重新加载配置时新的MConfig会沿用同一个flagOverlay
+ */
+type flagOverlay struct {
+	lock   sync.RWMutex
+	values map[string]*FlagValue
+}
+
+func newFlagOverlay() *flagOverlay {
+	return &flagOverlay{
+		values: make(map[string]*FlagValue, 0),
+	}
+}
+
+/*
+ * 返回key对应的FlagValue，同一个key多次调用返回同一个值
+ * 可以注册到pflag之类的FlagSet上，例如 pfs.Var(conf.FlagValue("port"), "port", "listen port")
+ */
+func (m *MConfig) FlagValue(key string) (*FlagValue, error) {
+	canonical, err := m.resolveKey(key)
+	if err != nil {
+		return nil, err
+	}
+	// 默认值要在加锁之前取，取值的过程中也会查找参数
+	def := m.flagDefault(canonical)
+	m.opts.flags.lock.Lock()
+	defer m.opts.flags.lock.Unlock()
+	if value, ok := m.opts.flags.values[canonical]; ok {
+		return value, nil
+	}
+	value := &FlagValue{def: def}
+	m.opts.flags.values[canonical] = value
+	return value, nil
+}
+
+/*
+ * 在fs上注册名为name的参数，绑定到key上，参数的默认值显示为当前配置中的值
+ */
+func (m *MConfig) BindFlag(fs *flag.FlagSet, name string, key string, usage string) error {
+	value, err := m.FlagValue(key)
+	if err != nil {
+		return err
+	}
+	fs.Var(value, name, usage)
+	return nil
+}
+
+/*
+ * 为每个key注册一个同名的参数，例如 BindFlags(flag.CommandLine, "port", "db.host")
+ */
+func (m *MConfig) BindFlags(fs *flag.FlagSet, keys ...string) error {
+	for _, key := range keys {
+		if err := m.BindFlag(fs, key, key, "config "+key); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+/*
+ * 查找key对应的命令行参数，没有绑定或者没有显式设置时返回false
+ */
+func (m *MConfig) lookupFlag(key string) (string, bool) {
+	if m.opts.flags == nil {
+		return "", false
+	}
+	m.opts.flags.lock.RLock()
+	bound := len(m.opts.flags.values) > 0
+	m.opts.flags.lock.RUnlock()
+	// 没有绑定任何参数时不需要规范化key，Get*的热点路径上避免分配内存
+	if !bound {
+		return "", false
+	}
+	canonical, err := canonicalKey(key)
+	if err != nil {
+		return "", false
+	}
+	m.opts.flags.lock.RLock()
+	value, ok := m.opts.flags.values[canonical]
+	m.opts.flags.lock.RUnlock()
+	if !ok {
+		return "", false
+	}
+	return value.get()
+}
+
+/*
+ * 当前配置中key的值，字符串去掉引号，其他类型保持json的写法
+ */
+func (m *MConfig) flagDefault(key string) string {
+	raw, err := m.GetRawMessage(key)
+	if err != nil {
+		return ""
+	}
+	var strVal string
+	if err := json.Unmarshal(raw, &strVal); err == nil {
+		return strVal
+	}
+	return string(raw)
+}
@@ -0,0 +1,77 @@
+package conf
+
+import (
+	"flag"
+	"testing"
+)
+
+func TestBindFlags(t *testing.T) {
+	SetConfig("flag", "testdir/test.json", nil, WithEnvOverlay("FLAGAPP"))
+	conf := MultiConfig("flag")
+
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	if err := conf.BindFlags(fs, "key1", "key2"); err != nil {
+		t.Fatalf("BindFlags error:%+v", err)
+	}
+	if err := conf.BindFlag(fs, "host", "key10.key11[0].key12", "host name"); err != nil {
+		t.Fatalf("BindFlag error:%+v", err)
+	}
+	if def := fs.Lookup("key1").DefValue; def != "1" {
+		t.Errorf("flag key1 default = %s; expected %s", def, "1")
+	}
+	if def := fs.Lookup("host").DefValue; def != "value12" {
+		t.Errorf("flag host default = %s; expected %s", def, "value12")
+	}
+
+	t.Setenv("FLAGAPP_KEY1", "2")
+	t.Setenv("FLAGAPP_KEY2", "env_value2")
+	if err := fs.Parse([]string{"-key1", "3", "-host", "flag_value12"}); err != nil {
+		t.Fatalf("Parse error:%+v", err)
+	}
+
+	// 显式设置的参数优先级最高
+	val, err := conf.GetInt("key1")
+	if err != nil || val != 3 {
+		t.Errorf("GetInt(%s) = %d; expected %d, error:%+v", "key1", val, 3, err)
+	}
+	if source, _ := conf.Source("key1"); source != SourceFlag {
+		t.Errorf("Source(%s) = %s; expected %s", "key1", source, SourceFlag)
+	}
+
+	// 没有设置的参数不覆盖环境变量
+	val2, err := conf.GetString("key2")
+	if err != nil || val2 != "env_value2" {
+		t.Errorf("GetString(%s) = %s; expected %s, error:%+v", "key2", val2, "env_value2", err)
+	}
+
+	val3, err := conf.GetString("key10 . key11[ 0 ].key12")
+	if err != nil || val3 != "flag_value12" {
+		t.Errorf("GetString(%s) = %s; expected %s, error:%+v", "key10 . key11[ 0 ].key12", val3, "flag_value12", err)
+	}
+}
+
+func TestFlagValue(t *testing.T) {
+	SetConfig("flag_value", "testdir/test.json", nil)
+	conf := MultiConfig("flag_value")
+
+	value, err := conf.FlagValue("key2")
+	if err != nil {
+		t.Fatalf("FlagValue error:%+v", err)
+	}
+	again, _ := conf.FlagValue("key2")
+	if value != again {
+		t.Errorf("FlagValue(%s) should return the same value", "key2")
+	}
+	if value.Type() != "string" || value.String() != "value2" || value.IsSet() {
+		t.Errorf("FlagValue(%s) = %s; expected unset default %s", "key2", value.String(), "value2")
+	}
+	value.Set("set_value2")
+	val, err := conf.GetString("key2")
+	if err != nil || val != "set_value2" {
+		t.Errorf("GetString(%s) = %s; expected %s, error:%+v", "key2", val, "set_value2", err)
+	}
+
+	if _, err := conf.FlagValue("key1..key2"); err != InvalidKeyErr {
+		t.Errorf("FlagValue with empty elem error = %+v; expected %+v", err, InvalidKeyErr)
+	}
+}
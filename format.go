@@ -0,0 +1,224 @@
+package conf
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/BurntSushi/toml"
+	"gopkg.in/yaml.v3"
+)
+
+var UnknownFormatErr = errors.New("unknown config format")
+
+// 配置文件格式
+type Format int
+
+const (
+	// 根据文件扩展名自动识别，无法识别时按json处理
+	AutoFormat Format = iota
+	JSONFormat
+	YAMLFormat
+	TOMLFormat
+	// 以下格式没有类型信息，所有的值都是字符串，由Get*方法负责转换
+	INIFormat
+	PropertiesFormat
+	DotenvFormat
+)
+
+func (f Format) String() string {
+	switch f {
+	case AutoFormat:
+		return "auto"
+	case JSONFormat:
+		return "json"
+	case YAMLFormat:
+		return "yaml"
+	case TOMLFormat:
+		return "toml"
+	case INIFormat:
+		return "ini"
+	case PropertiesFormat:
+		return "properties"
+	case DotenvFormat:
+		return "dotenv"
+	}
+	return fmt.Sprintf("format(%d)", int(f))
+}
+
+/*
+ * 格式本身是否没有类型信息
+ */
+func (f Format) untyped() bool {
+	return f == INIFormat || f == PropertiesFormat || f == DotenvFormat
+}
+
+/*
+ * 解码器负责把文件内容转换成travel可以遍历的rawEntryMap
+ * 不管是什么格式，最终都统一成json的片段，这样所有的Get*方法都不需要关心格式
+ */
+type decoder func(content []byte) (map[string]json.RawMessage, error)
+
+var decoders = map[Format]decoder{
+	JSONFormat:       decodeJSON,
+	YAMLFormat:       decodeYAML,
+	TOMLFormat:       decodeTOML,
+	INIFormat:        decodeINI,
+	PropertiesFormat: decodeProperties,
+	DotenvFormat:     decodeDotenv,
+}
+
+var extFormats = map[string]Format{
+	".json":       JSONFormat,
+	".yaml":       YAMLFormat,
+	".yml":        YAMLFormat,
+	".toml":       TOMLFormat,
+	".ini":        INIFormat,
+	".properties": PropertiesFormat,
+	".env":        DotenvFormat,
+}
+
+/*
+ * 确定配置文件的格式，显式指定的优先，否则根据扩展名判断
+ */
+func detectFormat(p string, format Format) Format {
+	if format != AutoFormat {
+		return format
+	}
+	if f, ok := extFormats[strings.ToLower(filepath.Ext(p))]; ok {
+		return f
+	}
+	return JSONFormat
+}
+
+func decodeContent(content []byte, format Format) (map[string]json.RawMessage, error) {
+	dec, ok := decoders[format]
+	if !ok {
+		return nil, UnknownFormatErr
+	}
+	return dec(content)
+}
+
+func decodeJSON(content []byte) (map[string]json.RawMessage, error) {
+	rawMap := make(map[string]json.RawMessage, 0)
+	if err := json.Unmarshal(content, &rawMap); err != nil {
+		return nil, err
+	}
+	return rawMap, nil
+}
+
+func decodeYAML(content []byte) (map[string]json.RawMessage, error) {
+	var doc interface{}
+	if err := yaml.Unmarshal(content, &doc); err != nil {
+		return nil, err
+	}
+	// 空文档
+	if doc == nil {
+		return make(map[string]json.RawMessage, 0), nil
+	}
+	doc = normalizeYAML(doc)
+	if _, ok := doc.(map[string]interface{}); !ok {
+		return nil, fmt.Errorf("yaml document root must be a mapping, got %T", doc)
+	}
+	return toRawMap(doc)
+}
+
+/*
+ * yaml的mapping允许非字符串的key，json不允许，这里统一把key转换成字符串
+ */
+func normalizeYAML(val interface{}) interface{} {
+	switch v := val.(type) {
+	case map[string]interface{}:
+		for k, item := range v {
+			v[k] = normalizeYAML(item)
+		}
+		return v
+	case map[interface{}]interface{}:
+		m := make(map[string]interface{}, len(v))
+		for k, item := range v {
+			m[fmt.Sprint(k)] = normalizeYAML(item)
+		}
+		return m
+	case []interface{}:
+		for i, item := range v {
+			v[i] = normalizeYAML(item)
+		}
+		return v
+	}
+	return val
+}
+
+func decodeTOML(content []byte) (map[string]json.RawMessage, error) {
+	rawMap, _, err := decodeTOMLDatetimes(content)
+	return rawMap, err
+}
+
+/*
+ * 解码toml，同时返回原生日期时间的位置，加载配置时用来标记节点树，见GetDatetime
+ */
+func decodeTOMLDatetimes(content []byte) (map[string]json.RawMessage, map[string]DatetimeKind, error) {
+	doc := make(map[string]interface{}, 0)
+	if err := toml.Unmarshal(content, &doc); err != nil {
+		return nil, nil, err
+	}
+	datetimes := make(map[string]DatetimeKind, 0)
+	rawMap, err := toRawMap(normalizeTOML(doc, nil, datetimes))
+	if err != nil {
+		return nil, nil, err
+	}
+	return rawMap, datetimes, nil
+}
+
+/*
+ * toml的日期时间是原生类型，json中没有对应的类型，这里按照种类对应的格式转换成字符串，
+ * 种类记录在datetimes中，key为规范写法的完整key
+ * 不带时区的本地日期时间保持本地格式，不能被json编码成UTC时间
+ */
+func normalizeTOML(val interface{}, path Path, datetimes map[string]DatetimeKind) interface{} {
+	switch v := val.(type) {
+	case map[string]interface{}:
+		for k, item := range v {
+			v[k] = normalizeTOML(item, appendPath(path, PathElem{Key: k}), datetimes)
+		}
+		return v
+	case []map[string]interface{}:
+		tables := make([]interface{}, len(v))
+		for i, item := range v {
+			tables[i] = normalizeTOML(item, appendPath(path, PathElem{Index: i, IsIndex: true}), datetimes)
+		}
+		return tables
+	case []interface{}:
+		for i, item := range v {
+			v[i] = normalizeTOML(item, appendPath(path, PathElem{Index: i, IsIndex: true}), datetimes)
+		}
+		return v
+	case time.Time:
+		// toml库用固定名字的时区标记本地日期时间
+		kind := OffsetDatetime
+		switch v.Location().String() {
+		case "datetime-local":
+			kind = LocalDatetime
+		case "date-local":
+			kind = LocalDate
+		case "time-local":
+			kind = LocalTime
+		}
+		datetimes[path.String()] = kind
+		return v.Format(kind.layout())
+	}
+	return val
+}
+
+/*
+ * 把解码出来的通用结构重新编码成json片段
+ */
+func toRawMap(doc interface{}) (map[string]json.RawMessage, error) {
+	content, err := json.Marshal(doc)
+	if err != nil {
+		return nil, err
+	}
+	return decodeJSON(content)
+}
@@ -0,0 +1,354 @@
+package conf
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+/*
+ * ini/properties/dotenv这类key=value格式的解析
+ * key中的.会被展开成嵌套的对象，例如db.host=localhost会变成{"db": {"host": "localhost"}}
+ * 这样就可以和json一样使用GetString("db.host")获取
+ */
+
+/*
+ * 同一个key既是值又有子key时(例如properties中的log4j.appender.X和log4j.appender.X.layout)，
+ * 值保存在对象中名为leafKey的字段里，没有类型的配置读取对象时会使用这个值，见node.leaf
+ */
+const leafKey = ""
+
+/*
+ * 对象中leafKey保存的值，按照值解析同时有值和子key的对象时使用
+ */
+func leafRaw(raw json.RawMessage) (json.RawMessage, bool) {
+	if rawKind(raw) != '{' {
+		return nil, false
+	}
+	var rawMap map[string]json.RawMessage
+	if json.Unmarshal(raw, &rawMap) != nil {
+		return nil, false
+	}
+	leaf, ok := rawMap[leafKey]
+	return leaf, ok
+}
+
+/*
+ * 去掉各级对象中的leafKey，GetRawMessage、Query这类对外返回原始片段的地方使用
+ */
+func stripLeaf(raw json.RawMessage) json.RawMessage {
+	if !bytes.Contains(raw, []byte(`"":`)) {
+		return raw
+	}
+	dec := json.NewDecoder(bytes.NewReader(raw))
+	dec.UseNumber()
+	var val interface{}
+	if dec.Decode(&val) != nil {
+		return raw
+	}
+	stripped, err := json.Marshal(removeLeaf(val))
+	if err != nil {
+		return raw
+	}
+	return stripped
+}
+
+func removeLeaf(val interface{}) interface{} {
+	switch v := val.(type) {
+	case map[string]interface{}:
+		delete(v, leafKey)
+		for key, item := range v {
+			v[key] = removeLeaf(item)
+		}
+	case []interface{}:
+		for i, item := range v {
+			v[i] = removeLeaf(item)
+		}
+	}
+	return val
+}
+
+/*
+ * 按照path把value放到树中对应的位置，中间节点不存在时自动创建
+ */
+func setPath(tree map[string]interface{}, path []string, value string) error {
+	node := tree
+	for i, elem := range path {
+		elem = strings.TrimSpace(elem)
+		if elem == "" {
+			return InvalidKeyErr
+		}
+		if i == len(path)-1 {
+			if childMap, ok := node[elem].(map[string]interface{}); ok {
+				childMap[leafKey] = value
+			} else {
+				node[elem] = value
+			}
+			return nil
+		}
+		child, ok := node[elem]
+		if !ok {
+			child = make(map[string]interface{}, 0)
+			node[elem] = child
+		}
+		childMap, ok := child.(map[string]interface{})
+		if !ok {
+			// 已经是值的key变成对象，原来的值保留下来
+			childMap = map[string]interface{}{leafKey: child}
+			node[elem] = childMap
+		}
+		node = childMap
+	}
+	return nil
+}
+
+/*
+ * ini格式
+ * [section] 下面的key=value会映射成section.key，section名中的.同样会展开
+ * 以;或者#开头的行是注释
+ */
+func decodeINI(content []byte) (map[string]json.RawMessage, error) {
+	tree := make(map[string]interface{}, 0)
+	var section []string
+	scanner := bufio.NewScanner(bytes.NewReader(content))
+	lineNo := 0
+	for scanner.Scan() {
+		lineNo++
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || line[0] == ';' || line[0] == '#' {
+			continue
+		}
+		if line[0] == '[' {
+			if line[len(line)-1] != ']' {
+				return nil, lineError(lineNo, fmt.Errorf("invalid section %s", line))
+			}
+			name := strings.TrimSpace(line[1 : len(line)-1])
+			if name == "" {
+				return nil, lineError(lineNo, errors.New("empty section"))
+			}
+			section = strings.Split(name, ".")
+			continue
+		}
+		sep := strings.IndexAny(line, "=:")
+		if sep <= 0 {
+			return nil, lineError(lineNo, fmt.Errorf("invalid entry %s", line))
+		}
+		key := strings.TrimSpace(line[:sep])
+		value := unquote(strings.TrimSpace(line[sep+1:]))
+		path := append(append([]string{}, section...), strings.Split(key, ".")...)
+		if err := setPath(tree, path, value); err != nil {
+			return nil, lineError(lineNo, err)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return toRawMap(tree)
+}
+
+/*
+ * java的properties格式
+ * 支持=、:或者空白分隔key和value，#和!开头的行是注释，行尾的\表示续行
+ */
+func decodeProperties(content []byte) (map[string]json.RawMessage, error) {
+	tree := make(map[string]interface{}, 0)
+	scanner := bufio.NewScanner(bytes.NewReader(content))
+	lineNo := 0
+	logical := bytes.NewBuffer([]byte{})
+	for scanner.Scan() {
+		lineNo++
+		line := strings.TrimLeft(scanner.Text(), " \t\f")
+		if logical.Len() == 0 && (line == "" || line[0] == '#' || line[0] == '!') {
+			continue
+		}
+		// 行尾奇数个\表示续行
+		slashes := len(line) - len(strings.TrimRight(line, "\\"))
+		if slashes%2 == 1 {
+			logical.WriteString(line[:len(line)-1])
+			continue
+		}
+		logical.WriteString(line)
+		key, value, err := splitProperty(logical.String())
+		logical.Reset()
+		if err != nil {
+			return nil, lineError(lineNo, err)
+		}
+		if err := setPath(tree, strings.Split(key, "."), value); err != nil {
+			return nil, lineError(lineNo, err)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	if logical.Len() > 0 {
+		key, value, err := splitProperty(logical.String())
+		if err != nil {
+			return nil, lineError(lineNo, err)
+		}
+		if err := setPath(tree, strings.Split(key, "."), value); err != nil {
+			return nil, lineError(lineNo, err)
+		}
+	}
+	return toRawMap(tree)
+}
+
+/*
+ * 拆分properties的一行，key在第一个未转义的=、:或者空白处结束
+ */
+func splitProperty(line string) (string, string, error) {
+	end := len(line)
+	for i := 0; i < len(line); i++ {
+		if line[i] == '\\' {
+			i++
+			continue
+		}
+		if strings.IndexByte("=: \t\f", line[i]) >= 0 {
+			end = i
+			break
+		}
+	}
+	key, err := unescapeProperty(line[:end])
+	if err != nil {
+		return "", "", err
+	}
+	if key == "" {
+		return "", "", InvalidKeyErr
+	}
+	rest := strings.TrimLeft(line[end:], " \t\f")
+	if rest != "" && (rest[0] == '=' || rest[0] == ':') {
+		rest = strings.TrimLeft(rest[1:], " \t\f")
+	}
+	value, err := unescapeProperty(rest)
+	if err != nil {
+		return "", "", err
+	}
+	return key, value, nil
+}
+
+func unescapeProperty(s string) (string, error) {
+	if strings.IndexByte(s, '\\') < 0 {
+		return s, nil
+	}
+	buf := bytes.NewBuffer([]byte{})
+	for i := 0; i < len(s); i++ {
+		if s[i] != '\\' || i == len(s)-1 {
+			buf.WriteByte(s[i])
+			continue
+		}
+		i++
+		switch s[i] {
+		case 't':
+			buf.WriteByte('\t')
+		case 'n':
+			buf.WriteByte('\n')
+		case 'r':
+			buf.WriteByte('\r')
+		case 'f':
+			buf.WriteByte('\f')
+		case 'u':
+			if i+4 >= len(s) {
+				return "", fmt.Errorf("invalid unicode escape in %s", s)
+			}
+			r, err := strconv.ParseUint(s[i+1:i+5], 16, 32)
+			if err != nil {
+				return "", fmt.Errorf("invalid unicode escape in %s", s)
+			}
+			buf.WriteRune(rune(r))
+			i += 4
+		default:
+			buf.WriteByte(s[i])
+		}
+	}
+	return buf.String(), nil
+}
+
+/*
+ * dotenv格式
+ * 支持export前缀、单双引号以及未加引号的值后面的 # 注释
+ */
+func decodeDotenv(content []byte) (map[string]json.RawMessage, error) {
+	tree := make(map[string]interface{}, 0)
+	scanner := bufio.NewScanner(bytes.NewReader(content))
+	lineNo := 0
+	for scanner.Scan() {
+		lineNo++
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || line[0] == '#' {
+			continue
+		}
+		line = strings.TrimPrefix(line, "export ")
+		sep := strings.IndexByte(line, '=')
+		if sep <= 0 {
+			return nil, lineError(lineNo, fmt.Errorf("invalid entry %s", line))
+		}
+		key := strings.TrimSpace(line[:sep])
+		value, err := parseDotenvValue(strings.TrimSpace(line[sep+1:]))
+		if err != nil {
+			return nil, lineError(lineNo, err)
+		}
+		if err := setPath(tree, strings.Split(key, "."), value); err != nil {
+			return nil, lineError(lineNo, err)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return toRawMap(tree)
+}
+
+func parseDotenvValue(s string) (string, error) {
+	if s == "" {
+		return "", nil
+	}
+	switch s[0] {
+	case '\'':
+		end := strings.IndexByte(s[1:], '\'')
+		if end < 0 {
+			return "", fmt.Errorf("unterminated quote in %s", s)
+		}
+		return s[1 : end+1], nil
+	case '"':
+		buf := bytes.NewBuffer([]byte{})
+		for i := 1; i < len(s); i++ {
+			switch s[i] {
+			case '"':
+				return buf.String(), nil
+			case '\\':
+				if i+1 < len(s) {
+					i++
+					switch s[i] {
+					case 'n':
+						buf.WriteByte('\n')
+					case 't':
+						buf.WriteByte('\t')
+					case 'r':
+						buf.WriteByte('\r')
+					default:
+						buf.WriteByte(s[i])
+					}
+				}
+			default:
+				buf.WriteByte(s[i])
+			}
+		}
+		return "", fmt.Errorf("unterminated quote in %s", s)
+	}
+	if i := strings.Index(s, " #"); i >= 0 {
+		s = s[:i]
+	}
+	return strings.TrimSpace(s), nil
+}
+
+/*
+ * 去掉ini值两边成对的引号
+ */
+func unquote(s string) string {
+	if len(s) >= 2 && (s[0] == '"' || s[0] == '\'') && s[len(s)-1] == s[0] {
+		return s[1 : len(s)-1]
+	}
+	return s
+}
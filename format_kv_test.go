@@ -0,0 +1,224 @@
+package conf
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestINIConfig(t *testing.T) {
+	SetConfig("ini", "testdir/test.ini", nil)
+	conf := MultiConfig("ini")
+
+	val, err := conf.GetString("db.host")
+	if err != nil || val != "127.0.0.1" {
+		t.Errorf("GetString(%s) = %s; expected %s, error:%+v", "db.host", val, "127.0.0.1", err)
+	}
+
+	val2, err := conf.GetInt("db.port")
+	if err != nil || val2 != 3306 {
+		t.Errorf("GetInt(%s) = %d; expected %d, error:%+v", "db.port", val2, 3306, err)
+	}
+
+	val3, err := conf.GetFloat("db.timeout")
+	if err != nil || val3 != 1.5 {
+		t.Errorf("GetFloat(%s) = %f; expected %f, error:%+v", "db.timeout", val3, 1.5, err)
+	}
+
+	val4, err := conf.GetBool("db.debug")
+	if err != nil || val4 != true {
+		t.Errorf("GetBool(%s) = %t; expected %t, error:%+v", "db.debug", val4, true, err)
+	}
+
+	val5, err := conf.GetStringSlice("db.tags")
+	if err != nil || len(val5) != 3 || val5[1] != "b" {
+		t.Errorf("GetStringSlice(%s) = %v; expected [a b c], error:%+v", "db.tags", val5, err)
+	}
+
+	val6, err := conf.GetString("cache.redis.addr")
+	if err != nil || val6 != "127.0.0.1:6379" {
+		t.Errorf("GetString(%s) = %s; expected %s, error:%+v", "cache.redis.addr", val6, "127.0.0.1:6379", err)
+	}
+
+	val7, err := conf.GetString("name")
+	if err != nil || val7 != "legacy" {
+		t.Errorf("GetString(%s) = %s; expected %s, error:%+v", "name", val7, "legacy", err)
+	}
+
+	if _, err := conf.GetInt("db.host"); err == nil {
+		t.Errorf("GetInt(%s) should fail on non numeric value", "db.host")
+	}
+}
+
+func TestPropertiesConfig(t *testing.T) {
+	SetConfig("properties", "testdir/test.properties", nil)
+	conf := MultiConfig("properties")
+
+	val, err := conf.GetString("db.host")
+	if err != nil || val != "127.0.0.1" {
+		t.Errorf("GetString(%s) = %s; expected %s, error:%+v", "db.host", val, "127.0.0.1", err)
+	}
+
+	val2, err := conf.GetInt("db.port")
+	if err != nil || val2 != 3306 {
+		t.Errorf("GetInt(%s) = %d; expected %d, error:%+v", "db.port", val2, 3306, err)
+	}
+
+	val3, err := conf.GetBool("db.debug")
+	if err != nil || val3 != true {
+		t.Errorf("GetBool(%s) = %t; expected %t, error:%+v", "db.debug", val3, true, err)
+	}
+
+	val4, err := conf.GetString("app.title")
+	if err != nil || val4 != "hello world" {
+		t.Errorf("GetString(%s) = %s; expected %s, error:%+v", "app.title", val4, "hello world", err)
+	}
+
+	val5, err := conf.GetString("app.unicode")
+	if err != nil || val5 != "中文" {
+		t.Errorf("GetString(%s) = %s; expected %s, error:%+v", "app.unicode", val5, "中文", err)
+	}
+}
+
+func TestDotenvConfig(t *testing.T) {
+	SetConfig("dotenv", "testdir/test.env", nil)
+	conf := MultiConfig("dotenv")
+
+	val, err := conf.GetString("DB_HOST")
+	if err != nil || val != "127.0.0.1" {
+		t.Errorf("GetString(%s) = %s; expected %s, error:%+v", "DB_HOST", val, "127.0.0.1", err)
+	}
+
+	val2, err := conf.GetInt("DB_PORT")
+	if err != nil || val2 != 3306 {
+		t.Errorf("GetInt(%s) = %d; expected %d, error:%+v", "DB_PORT", val2, 3306, err)
+	}
+
+	val3, err := conf.GetString("GREETING")
+	if err != nil || val3 != "hello\nworld" {
+		t.Errorf("GetString(%s) = %q; expected %q, error:%+v", "GREETING", val3, "hello\nworld", err)
+	}
+
+	val4, err := conf.GetString("RAW")
+	if err != nil || val4 != "a # b" {
+		t.Errorf("GetString(%s) = %s; expected %s, error:%+v", "RAW", val4, "a # b", err)
+	}
+
+	val5, err := conf.GetBool("DEBUG")
+	if err != nil || val5 != true {
+		t.Errorf("GetBool(%s) = %t; expected %t, error:%+v", "DEBUG", val5, true, err)
+	}
+}
+
+func TestKeyConflict(t *testing.T) {
+	cases := []struct {
+		content string
+		format  Format
+	}{
+		{"a=1\na.b=2\n", PropertiesFormat},
+		{"a.b=2\na=1\n", PropertiesFormat},
+		{"[x]\na=1\n[x.a]\nb=2\n", INIFormat},
+	}
+	for _, c := range cases {
+		rawMap, err := decodeContent([]byte(c.content), c.format)
+		if err != nil {
+			t.Fatalf("decodeContent(%q) error:%+v", c.content, err)
+		}
+		root, _ := buildTree(rawMap)
+		conf := &MConfig{root: root, rawEntryMap: rawMap, format: c.format, opts: configOptions{flags: newFlagOverlay()}}
+		prefix := ""
+		if c.format == INIFormat {
+			prefix = "x."
+		}
+		// 同一个key既是值又有子key时两个值都能取到
+		val, err := conf.GetInt(prefix + "a")
+		if err != nil || val != 1 {
+			t.Errorf("GetInt(%s) = %d; expected %d, error:%+v", prefix+"a", val, 1, err)
+		}
+		val, err = conf.GetInt(prefix + "a.b")
+		if err != nil || val != 2 {
+			t.Errorf("GetInt(%s) = %d; expected %d, error:%+v", prefix+"a.b", val, 2, err)
+		}
+	}
+}
+
+func newKVConfig(t *testing.T, content string) *MConfig {
+	rawMap, err := decodeContent([]byte(content), PropertiesFormat)
+	if err != nil {
+		t.Fatalf("decodeContent(%q) error:%+v", content, err)
+	}
+	root, err := buildTree(rawMap)
+	if err != nil {
+		t.Fatalf("buildTree error:%+v", err)
+	}
+	return &MConfig{root: root, rawEntryMap: rawMap, format: PropertiesFormat, opts: configOptions{flags: newFlagOverlay()}}
+}
+
+/*
+ * 同时有值和子key的对象，保存值的leafKey只在内部使用
+ */
+func TestLeafValue(t *testing.T) {
+	conf := newKVConfig(t, "log.appender.X=console\nlog.appender.X.layout=json\n")
+
+	var appender struct {
+		X string
+	}
+	if err := conf.Unmarshal("log.appender", &appender); err != nil || appender.X != "console" {
+		t.Errorf("Unmarshal(%s) = %+v; expected X=console, error:%+v", "log.appender", appender, err)
+	}
+	var fields map[string]string
+	if err := conf.Unmarshal("log.appender.X", &fields); err != nil || !reflect.DeepEqual(fields, map[string]string{"layout": "json"}) {
+		t.Errorf("Unmarshal(%s) = %v; expected map[layout:json], error:%+v", "log.appender.X", fields, err)
+	}
+	var layout struct {
+		Layout string
+	}
+	if err := conf.Unmarshal("log.appender.X", &layout); err != nil || layout.Layout != "json" {
+		t.Errorf("Unmarshal(%s) = %+v; expected Layout=json, error:%+v", "log.appender.X", layout, err)
+	}
+
+	raw, err := conf.GetRawMessage("log.appender.X")
+	if err != nil || string(raw) != `{"layout":"json"}` {
+		t.Errorf("GetRawMessage(%s) = %s; expected %s, error:%+v", "log.appender.X", raw, `{"layout":"json"}`, err)
+	}
+
+	results, err := conf.Query("$..*")
+	if err != nil || len(results) != 4 {
+		t.Errorf("Query(%s) = %+v; expected 4 results, error:%+v", "$..*", results, err)
+	}
+	for _, r := range results {
+		if strings.Contains(r.Path, `[""]`) || strings.Contains(string(r.Value), `"":`) {
+			t.Errorf("Query(%s) result %s = %s; leafKey should be hidden", "$..*", r.Path, r.Value)
+		}
+	}
+
+	cases := []struct {
+		old, new        string
+		added, modified []string
+	}{
+		{"log.appender.X=console\nlog.appender.X.layout=json\n", "log.appender.X=file\nlog.appender.X.layout=json\n", nil, []string{"log.appender.X"}},
+		{"log.appender.X=console\n", "log.appender.X=console\nlog.appender.X.layout=json\n", []string{"log.appender.X.layout"}, nil},
+		{"log.appender.X.layout=json\n", "log.appender.X=console\nlog.appender.X.layout=json\n", []string{"log.appender.X"}, nil},
+	}
+	for _, c := range cases {
+		event := newChangeEvent("kv", newKVConfig(t, c.old), newKVConfig(t, c.new))
+		if !reflect.DeepEqual(event.Added, c.added) || !reflect.DeepEqual(event.Modified, c.modified) || len(event.Removed) != 0 {
+			t.Errorf("newChangeEvent(%q, %q) = %+v; expected added %v, modified %v", c.old, c.new, event, c.added, c.modified)
+		}
+	}
+}
+
+func TestTypedFormatNotWeak(t *testing.T) {
+	rawMap, err := decodeContent([]byte(`{"port": "8080"}`), JSONFormat)
+	if err != nil {
+		t.Fatalf("decode json error:%+v", err)
+	}
+	root, err := buildTree(rawMap)
+	if err != nil {
+		t.Fatalf("buildTree error:%+v", err)
+	}
+	conf := &MConfig{rawEntryMap: rawMap, root: root, format: JSONFormat}
+	if _, err := conf.GetInt("port"); err == nil {
+		t.Errorf("GetInt on json string should keep failing")
+	}
+}
@@ -0,0 +1,173 @@
+package conf
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+)
+
+func TestYAMLConfig(t *testing.T) {
+	SetConfig("yaml", "testdir/test.yaml", nil)
+	conf := MultiConfig("yaml")
+	if conf.format != YAMLFormat {
+		t.Fatalf("format = %s; expected %s", conf.format, YAMLFormat)
+	}
+
+	val, err := conf.GetInt("key1")
+	if err != nil || val != 1 {
+		t.Errorf("GetInt(%s) = %d; expected %d, error:%+v", "key1", val, 1, err)
+	}
+
+	val2, err := conf.GetString("key10.key11[0].key12")
+	if err != nil || val2 != "value12" {
+		t.Errorf("GetString(%s) = %s; expected %s, error:%+v", "key10.key11[0].key12", val2, "value12", err)
+	}
+
+	val3, err := conf.GetIntSlice("key8.key9")
+	if err != nil || len(val3) != 3 || val3[2] != 3 {
+		t.Errorf("GetIntSlice(%s) = %v; expected [1 2 3], error:%+v", "key8.key9", val3, err)
+	}
+
+	val4, err := conf.GetFloat("key4")
+	if err != nil || val4 != 0.1 {
+		t.Errorf("GetFloat(%s) = %f; expected %f, error:%+v", "key4", val4, 0.1, err)
+	}
+
+	raw, err := conf.GetRawMessage("key5")
+	if err != nil {
+		t.Fatalf("GetRawMessage(%s), error:%+v", "key5", err)
+	}
+	var obj map[string]string
+	if err := json.Unmarshal(raw, &obj); err != nil || obj["key6"] != "value6" {
+		t.Errorf("GetRawMessage(%s) = %s; expected key6=value6, error:%+v", "key5", raw, err)
+	}
+
+	// 修改返回的片段不影响配置
+	for i := range raw {
+		raw[i] = ' '
+	}
+	raw, err = conf.GetRawMessage("key5")
+	obj = nil
+	if err != nil || json.Unmarshal(raw, &obj) != nil || obj["key6"] != "value6" {
+		t.Errorf("GetRawMessage(%s) = %s; expected key6=value6, error:%+v", "key5", raw, err)
+	}
+}
+
+func TestExplicitFormat(t *testing.T) {
+	SetConfig("yaml_conf", "testdir/yaml.conf", nil, WithFormat(YAMLFormat))
+	val, err := MultiConfig("yaml_conf").GetString("key5.key6")
+	if err != nil || val != "value6" {
+		t.Errorf("GetString(%s) = %s; expected %s, error:%+v", "key5.key6", val, "value6", err)
+	}
+}
+
+func TestDecodeYAMLRoot(t *testing.T) {
+	if _, err := decodeContent([]byte("- a\n- b\n"), YAMLFormat); err == nil {
+		t.Errorf("decode yaml sequence root should fail")
+	}
+	rawMap, err := decodeContent([]byte(""), YAMLFormat)
+	if err != nil || len(rawMap) != 0 {
+		t.Errorf("decode empty yaml = %v; expected empty map, error:%+v", rawMap, err)
+	}
+	rawMap, err = decodeContent([]byte("1: one\n"), YAMLFormat)
+	if err != nil || string(rawMap["1"]) != `"one"` {
+		t.Errorf("decode yaml int key = %v; expected key \"1\", error:%+v", rawMap, err)
+	}
+}
+
+func TestTOMLConfig(t *testing.T) {
+	SetConfig("toml", "testdir/test.toml", nil)
+	conf := MultiConfig("toml")
+
+	val, err := conf.GetString("servers[1].host")
+	if err != nil || val != "10.0.0.2" {
+		t.Errorf("GetString(%s) = %s; expected %s, error:%+v", "servers[1].host", val, "10.0.0.2", err)
+	}
+
+	val2, err := conf.GetInt("servers[0].port")
+	if err != nil || val2 != 8080 {
+		t.Errorf("GetInt(%s) = %d; expected %d, error:%+v", "servers[0].port", val2, 8080, err)
+	}
+
+	val3, err := conf.GetString("key5.key6")
+	if err != nil || val3 != "value6" {
+		t.Errorf("GetString(%s) = %s; expected %s, error:%+v", "key5.key6", val3, "value6", err)
+	}
+
+	created, err := conf.GetTime("created")
+	expected := time.Date(1979, time.May, 27, 15, 32, 0, 0, time.UTC)
+	if err != nil || !created.Equal(expected) {
+		t.Errorf("GetTime(%s) = %s; expected %s, error:%+v", "created", created, expected, err)
+	}
+
+	localDatetime, err := conf.GetTime("local_datetime")
+	expected = time.Date(1979, time.May, 27, 7, 32, 0, 0, time.Local)
+	if err != nil || !localDatetime.Equal(expected) {
+		t.Errorf("GetTime(%s) = %s; expected %s, error:%+v", "local_datetime", localDatetime, expected, err)
+	}
+
+	localDate, err := conf.GetTime("local_date")
+	expected = time.Date(1979, time.May, 27, 0, 0, 0, 0, time.Local)
+	if err != nil || !localDate.Equal(expected) {
+		t.Errorf("GetTime(%s) = %s; expected %s, error:%+v", "local_date", localDate, expected, err)
+	}
+
+	localTime, err := conf.GetTime("local_time")
+	if err != nil || localTime.Hour() != 7 || localTime.Minute() != 32 {
+		t.Errorf("GetTime(%s) = %s; expected 07:32, error:%+v", "local_time", localTime, err)
+	}
+
+	date, err := conf.GetTime("dates[1]")
+	expected = time.Date(1980, time.January, 1, 0, 0, 0, 0, time.Local)
+	if err != nil || !date.Equal(expected) {
+		t.Errorf("GetTime(%s) = %s; expected %s, error:%+v", "dates[1]", date, expected, err)
+	}
+
+	// toml中的字符串不是时间，即使写法和日期时间相同
+	for _, key := range []string{"key2", "quoted"} {
+		if _, err := conf.GetTime(key); err != TypeErr {
+			t.Errorf("GetTime(%s) error = %+v; expected %+v", key, err, TypeErr)
+		}
+		if _, _, err := conf.GetDatetime(key); err != TypeErr {
+			t.Errorf("GetDatetime(%s) error = %+v; expected %+v", key, err, TypeErr)
+		}
+	}
+
+	kindCases := map[string]DatetimeKind{
+		"created":        OffsetDatetime,
+		"local_datetime": LocalDatetime,
+		"local_date":     LocalDate,
+		"local_time":     LocalTime,
+		"dates[1]":       LocalDate,
+	}
+	for key, expected := range kindCases {
+		_, kind, err := conf.GetDatetime(key)
+		if err != nil || kind != expected {
+			t.Errorf("GetDatetime(%s) kind = %s; expected %s, error:%+v", key, kind, expected, err)
+		}
+	}
+	created, kind, err := conf.GetDatetime("created")
+	if expected := time.Date(1979, time.May, 27, 15, 32, 0, 0, time.UTC); err != nil || !created.Equal(expected) {
+		t.Errorf("GetDatetime(%s) = %s %s; expected %s, error:%+v", "created", created, kind, expected, err)
+	}
+}
+
+func TestTOMLLayerDatetime(t *testing.T) {
+	m := NewManager()
+	if err := m.SetLayeredConfig("toml_layer", []string{"testdir/test.toml", "testdir/layer_override.toml"}, nil); err != nil {
+		t.Fatalf("SetLayeredConfig error:%+v", err)
+	}
+	conf := m.MultiConfig("toml_layer")
+
+	// 上层覆盖成字符串之后不再是日期时间
+	if _, err := conf.GetTime("created"); err != TypeErr {
+		t.Errorf("GetTime(%s) error = %+v; expected %+v", "created", err, TypeErr)
+	}
+	localDate, kind, err := conf.GetDatetime("local_date")
+	if expected := time.Date(2000, time.January, 1, 0, 0, 0, 0, time.Local); err != nil || kind != LocalDate || !localDate.Equal(expected) {
+		t.Errorf("GetDatetime(%s) = %s %s; expected %s, error:%+v", "local_date", localDate, kind, expected, err)
+	}
+	if _, kind, err := conf.GetDatetime("local_datetime"); err != nil || kind != LocalDatetime {
+		t.Errorf("GetDatetime(%s) kind = %s; expected %s, error:%+v", "local_datetime", kind, LocalDatetime, err)
+	}
+}
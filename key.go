@@ -0,0 +1,351 @@
+package conf
+
+import (
+	"strconv"
+	"strings"
+)
+
+/*
+ * key中的一级，IsIndex为true时表示数组下标，否则表示对象中的key
+ * 下标为负数时从数组的末尾开始，例如-1表示最后一个元素
+ */
+type PathElem struct {
+	Key     string
+	Index   int
+	IsIndex bool
+}
+
+/*
+ * 解析之后的key，例如 key10.key11[0].key12 解析成 key10、key11、[0]、key12 四级
+ * String()返回规范的写法，缓存、命令行参数等都使用规范的写法作为索引
+ */
+type Path []PathElem
+
+/*
+ * 解析key，支持以下写法:
+ *   key10.key11[0].key12         每一级前后的空格会被去掉
+ *   matrix[1][2]、list[-1]        多维数组和负数下标
+ *   servers["a.b.com"].port      key中包含.、[、]等字符时用引号括起来，引号内可以用\转义
+ *   /key10/key11/0/key12         JSON Pointer(RFC 6901)，~1表示/，~0表示~
+ * 例如 "key10 . key11[ 0 ]" 和 "key10.key11[0]" 得到相同的Path
+ */
+func ParseKey(key string) (Path, error) {
+	path := make(Path, 0, strings.Count(key, ".")+1)
+	scanner := newKeyScanner(key)
+	for {
+		elem, ok := scanner.next()
+		if !ok {
+			break
+		}
+		path = append(path, elem)
+	}
+	if scanner.err != nil {
+		return nil, scanner.err
+	}
+	return path, nil
+}
+
+func (p Path) String() string {
+	buf := strings.Builder{}
+	for i, elem := range p {
+		switch {
+		case elem.IsIndex:
+			buf.WriteString("[")
+			buf.WriteString(strconv.Itoa(elem.Index))
+			buf.WriteString("]")
+		case needsQuote(elem.Key, i == 0):
+			buf.WriteString("[")
+			buf.WriteString(quoteKey(elem.Key))
+			buf.WriteString("]")
+		default:
+			if i > 0 {
+				buf.WriteString(".")
+			}
+			buf.WriteString(elem.Key)
+		}
+	}
+	return buf.String()
+}
+
+/*
+ * key不能直接写在.后面时需要用引号括起来
+ */
+func needsQuote(key string, first bool) bool {
+	if key == "" || key[0] == ' ' || key[len(key)-1] == ' ' || (first && key[0] == '/') {
+		return true
+	}
+	return strings.ContainsAny(key, ".[]")
+}
+
+func quoteKey(key string) string {
+	buf := strings.Builder{}
+	buf.WriteByte('"')
+	for i := 0; i < len(key); i++ {
+		if key[i] == '"' || key[i] == '\\' {
+			buf.WriteByte('\\')
+		}
+		buf.WriteByte(key[i])
+	}
+	buf.WriteByte('"')
+	return buf.String()
+}
+
+/*
+ * 读取s[pos]开始的单引号或者双引号括起来的字符串，返回内容和结束引号之后的位置
+ * \转义下一个字符，没有转义时直接返回s的子串，不分配内存
+ */
+func readQuoted(s string, pos int) (string, int, bool) {
+	quote := s[pos]
+	start := pos + 1
+	escaped := false
+	for i := start; i < len(s); i++ {
+		switch s[i] {
+		case '\\':
+			escaped = true
+			i++
+		case quote:
+			if !escaped {
+				return s[start:i], i + 1, true
+			}
+			buf := strings.Builder{}
+			for j := start; j < i; j++ {
+				if s[j] == '\\' {
+					j++
+				}
+				buf.WriteByte(s[j])
+			}
+			return buf.String(), i + 1, true
+		}
+	}
+	return "", len(s), false
+}
+
+/*
+ * 逐级读取key，节点树查找和ParseKey共用，除了转义之外不分配内存
+ */
+type keyScanner struct {
+	key string
+	pos int
+	// 以/开头的key按照JSON Pointer解析
+	pointer bool
+	started bool
+	err     error
+	// 为true时允许*，用于订阅的pattern，matchAny表示刚返回的一级是*
+	wildcard bool
+	matchAny bool
+}
+
+func newKeyScanner(key string) keyScanner {
+	return keyScanner{key: key, pointer: strings.HasPrefix(key, "/")}
+}
+
+/*
+ * 返回下一级，结束或者出错时返回false，出错的原因在err中
+ */
+func (s *keyScanner) next() (PathElem, bool) {
+	s.matchAny = false
+	if s.err != nil || (s.started && s.pos >= len(s.key)) {
+		return PathElem{}, false
+	}
+	first := !s.started
+	s.started = true
+	if s.pointer {
+		return s.pointerToken()
+	}
+	s.skipSpace()
+	if first {
+		if s.peek() == '[' {
+			return s.bracket()
+		}
+		return s.name()
+	}
+	switch s.peek() {
+	case '.':
+		s.pos++
+		s.skipSpace()
+		return s.name()
+	case '[':
+		return s.bracket()
+	case 0:
+		// 末尾的空格
+		return PathElem{}, false
+	}
+	return s.fail(InvalidKeyErr)
+}
+
+func (s *keyScanner) fail(err error) (PathElem, bool) {
+	s.err = err
+	return PathElem{}, false
+}
+
+func (s *keyScanner) peek() byte {
+	if s.pos >= len(s.key) {
+		return 0
+	}
+	return s.key[s.pos]
+}
+
+func (s *keyScanner) skipSpace() {
+	for s.peek() == ' ' {
+		s.pos++
+	}
+}
+
+/*
+ * .后面的key，到下一个.或者[为止
+ */
+func (s *keyScanner) name() (PathElem, bool) {
+	start := s.pos
+	for s.pos < len(s.key) && s.key[s.pos] != '.' && s.key[s.pos] != '[' {
+		if s.key[s.pos] == ']' {
+			return s.fail(InvalidKeyErr)
+		}
+		s.pos++
+	}
+	name := strings.TrimRight(s.key[start:s.pos], " ")
+	if name == "" {
+		return s.fail(InvalidKeyErr)
+	}
+	s.matchAny = s.wildcard && name == "*"
+	return PathElem{Key: name}, true
+}
+
+/*
+ * [0]、[-1]或者["a.b.com"]
+ */
+func (s *keyScanner) bracket() (PathElem, bool) {
+	s.pos++
+	s.skipSpace()
+	var elem PathElem
+	if c := s.peek(); c == '*' && s.wildcard {
+		s.pos++
+		elem, s.matchAny = PathElem{IsIndex: true}, true
+	} else if c == '"' || c == '\'' {
+		key, end, ok := readQuoted(s.key, s.pos)
+		if !ok {
+			return s.fail(InvalidKeyErr)
+		}
+		elem, s.pos = PathElem{Key: key}, end
+	} else {
+		start := s.pos
+		for c := s.peek(); c == '-' || (c >= '0' && c <= '9'); c = s.peek() {
+			s.pos++
+		}
+		index, err := strconv.Atoi(s.key[start:s.pos])
+		if err != nil {
+			return s.fail(InvalidKeyErr)
+		}
+		elem = PathElem{Index: index, IsIndex: true}
+	}
+	s.skipSpace()
+	if s.peek() != ']' {
+		return s.fail(InvalidKeyErr)
+	}
+	s.pos++
+	return elem, true
+}
+
+/*
+ * JSON Pointer中/后面的一级，在数组上是下标，在对象上是key，查找时再决定
+ */
+func (s *keyScanner) pointerToken() (PathElem, bool) {
+	s.pos++
+	start := s.pos
+	for s.pos < len(s.key) && s.key[s.pos] != '/' {
+		s.pos++
+	}
+	token := s.key[start:s.pos]
+	s.matchAny = s.wildcard && token == "*"
+	if strings.IndexByte(token, '~') < 0 {
+		return PathElem{Key: token}, true
+	}
+	buf := strings.Builder{}
+	for i := 0; i < len(token); i++ {
+		if token[i] != '~' {
+			buf.WriteByte(token[i])
+			continue
+		}
+		if i+1 >= len(token) || (token[i+1] != '0' && token[i+1] != '1') {
+			return s.fail(InvalidKeyErr)
+		}
+		if token[i+1] == '0' {
+			buf.WriteByte('~')
+		} else {
+			buf.WriteByte('/')
+		}
+		i++
+	}
+	return PathElem{Key: buf.String()}, true
+}
+
+/*
+ * JSON Pointer中数组下标的写法，0或者不以0开头的数字
+ */
+func arrayIndex(key string) (int, bool) {
+	if key == "" || (key[0] == '0' && len(key) > 1) {
+		return 0, false
+	}
+	for i := 0; i < len(key); i++ {
+		if key[i] < '0' || key[i] > '9' {
+			return 0, false
+		}
+	}
+	index, err := strconv.Atoi(key)
+	return index, err == nil
+}
+
+/*
+ * 把key规范化，例如 "key10 . key11[ 0 ]" 会变成 "key10.key11[0]"
+ * key本身已经是规范的写法时直接返回，Get*的热点路径上不分配内存
+ */
+func canonicalKey(key string) (string, error) {
+	if isCanonical(key) {
+		return key, nil
+	}
+	path, err := ParseKey(key)
+	if err != nil {
+		return "", err
+	}
+	return path.String(), nil
+}
+
+/*
+ * key是否和解析之后的Path.String()完全相同
+ * JSON Pointer、需要引号的key和可能是数组下标的数字不做比较，直接按照不规范处理
+ */
+func isCanonical(key string) bool {
+	var digits [20]byte
+	scanner := newKeyScanner(key)
+	if scanner.pointer {
+		return false
+	}
+	pos := 0
+	for {
+		elem, ok := scanner.next()
+		if !ok {
+			return scanner.err == nil && pos == len(key)
+		}
+		if elem.IsIndex {
+			index := strconv.AppendInt(digits[:0], int64(elem.Index), 10)
+			end := pos + len(index) + 2
+			if end > len(key) || key[pos] != '[' || key[pos+1:end-1] != string(index) || key[end-1] != ']' {
+				return false
+			}
+			pos = end
+			continue
+		}
+		if _, ok := arrayIndex(elem.Key); ok || needsQuote(elem.Key, pos == 0) {
+			return false
+		}
+		if pos > 0 {
+			if pos >= len(key) || key[pos] != '.' {
+				return false
+			}
+			pos++
+		}
+		if !strings.HasPrefix(key[pos:], elem.Key) {
+			return false
+		}
+		pos += len(elem.Key)
+	}
+}
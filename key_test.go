@@ -0,0 +1,234 @@
+package conf
+
+import (
+	"conf/fileutil"
+	"path/filepath"
+	"testing"
+)
+
+func TestParseKey(t *testing.T) {
+	cases := map[string]string{
+		"key10.key11[0].key12":       "key10.key11[0].key12",
+		"key10 . key11[ 0 ] . key12": "key10.key11[0].key12",
+		" key1 ":                     "key1",
+		"key1":                       "key1",
+		"key11[01]":                  "key11[1]",
+		"matrix[ 1 ][2]":             "matrix[1][2]",
+		"list[-1]":                   "list[-1]",
+		`servers["a.b.com"].port`:    `servers["a.b.com"].port`,
+		`servers['a.b.com'] . port`:  `servers["a.b.com"].port`,
+		`servers["port"]`:            "servers.port",
+		`["a.\"b"]`:                  `["a.\"b"]`,
+		"/key10/key11/0/key12":       "key10.key11.0.key12",
+		"/servers/c~1d/m~0n":         "servers.c/d.m~n",
+		"/":                          `[""]`,
+	}
+	for key, expected := range cases {
+		path, err := ParseKey(key)
+		if err != nil || path.String() != expected {
+			t.Errorf("ParseKey(%s) = %s; expected %s, error:%+v", key, path, expected, err)
+		}
+		canonical, err := canonicalKey(key)
+		if err != nil || canonical != expected {
+			t.Errorf("canonicalKey(%s) = %s; expected %s, error:%+v", key, canonical, expected, err)
+		}
+		// 需要引号的key总是走解析的流程，其他规范的key直接使用
+		if isCanonical(key) && key != expected {
+			t.Errorf("isCanonical(%s) = true; expected false", key)
+		}
+	}
+
+	path, _ := ParseKey("key10.key11[0].key12")
+	if len(path) != 4 || path[1].Key != "key11" || !path[2].IsIndex || path[2].Index != 0 || path[3].Key != "key12" {
+		t.Errorf("ParseKey(%s) = %+v; expected 4 elems", "key10.key11[0].key12", path)
+	}
+
+	errCases := map[string]error{
+		"":             InvalidKeyErr,
+		"key10..key11": InvalidKeyErr,
+		"key10.":       InvalidKeyErr,
+		"key11[a]":     InvalidKeyErr,
+		"key11[0]a":    InvalidKeyErr,
+		"key11]":       InvalidKeyErr,
+		`key11["a]`:    InvalidKeyErr,
+		"/key10/a~2":   InvalidKeyErr,
+	}
+	for key, expected := range errCases {
+		if _, err := ParseKey(key); err != expected {
+			t.Errorf("ParseKey(%s) error = %+v; expected %+v", key, err, expected)
+		}
+	}
+}
+
+func TestPathGet(t *testing.T) {
+	m := NewManager()
+	if err := m.SetConfig("path", "testdir/path.json", nil); err != nil {
+		t.Fatalf("SetConfig error:%+v", err)
+	}
+	conf := m.MultiConfig("path")
+
+	intCases := map[string]int{
+		`servers["a.b.com"].port`: 8080,
+		"/servers/a.b.com/port":   8080,
+		"/servers/c~1d/port":      9090,
+		"/servers/m~0n/port":      7070,
+		"matrix[1][2]":            6,
+		"matrix[-1][-3]":          4,
+		"/matrix/0/1":             2,
+	}
+	for key, expected := range intCases {
+		val, err := conf.GetInt(key)
+		if err != nil || val != expected {
+			t.Errorf("GetInt(%s) = %d; expected %d, error:%+v", key, val, expected, err)
+		}
+	}
+
+	strCases := map[string]string{
+		"list[-1]":              "c",
+		"/key10/key11/1/key12":  "value22",
+		"key10.key11[-2].key12": "value12",
+		"/0":                    "zero",
+		`["0"]`:                 "zero",
+	}
+	for key, expected := range strCases {
+		val, err := conf.GetString(key)
+		if err != nil || val != expected {
+			t.Errorf("GetString(%s) = %s; expected %s, error:%+v", key, val, expected, err)
+		}
+	}
+
+	errCases := map[string]error{
+		"list[-4]":     InvalidSliceIndexErr,
+		"/list/3":      InvalidSliceIndexErr,
+		"/list/01":     TypeErr,
+		"/servers/c/d": KeyNotFoundErr,
+		"matrix[0].a":  TypeErr,
+	}
+	for key, expected := range errCases {
+		if _, err := conf.GetRawMessage(key); err != expected {
+			t.Errorf("GetRawMessage(%s) error = %+v; expected %+v", key, err, expected)
+		}
+	}
+
+	// JSON Pointer和其他写法共用缓存和命令行参数
+	for _, key := range []string{"/key10/key11/1/key12", "key10.key11[1].key12", "key10.key11.1.key12"} {
+		if val, err := conf.GetString(key); err != nil || val != "value22" {
+			t.Errorf("GetString(%s) = %s; expected %s, error:%+v", key, val, "value22", err)
+		}
+	}
+	for _, key := range []string{"/key10/key11/1/key12", "key10.key11[1].key12", "key10.key11.1.key12"} {
+		_, ok := conf.parsedEntryMap.Load(cacheKey{path: key, typ: String})
+		if ok != (key == "key10.key11[1].key12") {
+			t.Errorf("cache entry %s found = %t; expected %t", key, ok, !ok)
+		}
+	}
+	pointerFlag, err := conf.FlagValue("/matrix/1/2")
+	if indexFlag, _ := conf.FlagValue("matrix[1][2]"); err != nil || pointerFlag != indexFlag {
+		t.Errorf("FlagValue(%s) = %p; expected %p, error:%+v", "/matrix/1/2", pointerFlag, indexFlag, err)
+	}
+
+	// Query返回的Path中需要引号的key会带上引号
+	results, err := conf.Query("servers.*.port")
+	if err != nil || len(results) != 3 || results[0].Path != `servers["a.b.com"].port` || results[1].Path != "servers.c/d.port" {
+		t.Errorf("Query(%s) = %+v; expected quoted paths, error:%+v", "servers.*.port", results, err)
+	}
+	for _, r := range results {
+		if _, err := conf.GetInt(r.Path); err != nil {
+			t.Errorf("GetInt(%s) error:%+v", r.Path, err)
+		}
+	}
+}
+
+func TestTypedCache(t *testing.T) {
+	m := NewManager()
+	if err := m.SetConfig("ini", "testdir/test.ini", nil); err != nil {
+		t.Fatalf("SetConfig error:%+v", err)
+	}
+	conf := m.MultiConfig("ini")
+
+	// 同一个key按照不同的类型读取，缓存互不影响
+	strVal, err := conf.GetString("db.port")
+	if err != nil || strVal != "3306" {
+		t.Errorf("GetString(%s) = %s; expected %s, error:%+v", "db.port", strVal, "3306", err)
+	}
+	intVal, err := conf.GetInt("db.port")
+	if err != nil || intVal != 3306 {
+		t.Errorf("GetInt(%s) = %d; expected %d, error:%+v", "db.port", intVal, 3306, err)
+	}
+	strVal, err = conf.GetString("db.port")
+	if err != nil || strVal != "3306" {
+		t.Errorf("GetString(%s) = %s; expected %s, error:%+v", "db.port", strVal, "3306", err)
+	}
+
+	// 写法不同的同一个key共用一个缓存
+	for _, key := range []string{"db.tags", " db . tags ", "db .tags"} {
+		tags, err := conf.GetStringSlice(key)
+		if err != nil || len(tags) != 3 {
+			t.Errorf("GetStringSlice(%s) = %v; expected [a b c], error:%+v", key, tags, err)
+		}
+	}
+	entries := map[cacheKey]bool{}
+	conf.parsedEntryMap.Range(func(key, val interface{}) bool {
+		entries[key.(cacheKey)] = true
+		return true
+	})
+	expected := map[cacheKey]bool{
+		{path: "db.port", typ: String}:      true,
+		{path: "db.port", typ: Int}:         true,
+		{path: "db.tags", typ: StringSlice}: true,
+	}
+	if len(entries) != len(expected) {
+		t.Errorf("cache entries = %v; expected %v", entries, expected)
+	}
+	for key := range expected {
+		if !entries[key] {
+			t.Errorf("cache entry %+v not found, entries:%v", key, entries)
+		}
+	}
+}
+
+/*
+ * 不规范的key的解析结果按照MConfig缓存，重新加载之后按照新的节点树解析
+ */
+func TestResolveKeyReload(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "resolve.json")
+	fileutil.WriteContent(path, `{"list": [1, 2]}`)
+	m := NewManager()
+	if err := m.SetConfig("resolve", path, nil); err != nil {
+		t.Fatalf("SetConfig error:%+v", err)
+	}
+	conf := m.MultiConfig("resolve")
+	value, err := conf.FlagValue("/list/0")
+	if err != nil {
+		t.Fatalf("FlagValue error:%+v", err)
+	}
+	value.Set("9")
+	if val, err := conf.GetInt("/list/0"); err != nil || val != 9 {
+		t.Errorf("GetInt(%s) = %d; expected %d, error:%+v", "/list/0", val, 9, err)
+	}
+	allocs := testing.AllocsPerRun(100, func() {
+		conf.GetInt("/list/1")
+	})
+	if allocs != 0 {
+		t.Errorf("GetInt(%s) allocs = %v; expected 0", "/list/1", allocs)
+	}
+
+	// 仍然是数组时绑定的参数继续生效
+	fileutil.WriteContent(path, `{"list": [5, 6, 7]}`)
+	m.checkUpdate()
+	conf = m.MultiConfig("resolve")
+	if val, err := conf.GetInt("/list/0"); err != nil || val != 9 {
+		t.Errorf("GetInt(%s) = %d; expected %d, error:%+v", "/list/0", val, 9, err)
+	}
+	if val, err := conf.GetInt("/list/2"); err != nil || val != 7 {
+		t.Errorf("GetInt(%s) = %d; expected %d, error:%+v", "/list/2", val, 7, err)
+	}
+
+	// 数组变成对象之后0是对象的key，不再对应绑定的list[0]
+	fileutil.WriteContent(path, `{"list": {"0": 3}}`)
+	m.checkUpdate()
+	conf = m.MultiConfig("resolve")
+	if val, err := conf.GetInt("/list/0"); err != nil || val != 3 {
+		t.Errorf("GetInt(%s) = %d; expected %d, error:%+v", "/list/0", val, 3, err)
+	}
+}
@@ -0,0 +1,134 @@
+package conf
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+)
+
+// 分层配置中数组的合并方式，对象总是深度合并
+type ArrayMergeMode int
+
+const (
+	// 高优先级的数组直接替换低优先级的数组
+	ArrayReplace ArrayMergeMode = iota
+	// 高优先级的数组追加到低优先级的数组后面
+	ArrayAppend
+)
+
+/*
+ * 指定分层配置中数组的合并方式，默认为ArrayReplace
+ */
+func WithArrayMerge(mode ArrayMergeMode) ConfigOption {
+	return func(o *configOptions) {
+		o.arrayMerge = mode
+	}
+}
+
+/*
+ * 加载分层配置，每一层都是一个独立的MConfig，合并之后的结果作为这个配置的rawEntryMap，
+ * travel直接在合并后的视图上查找，得到的就是按优先级解析之后的值
+ * 任意一层加载失败都返回错误
+ */
+func newLayeredMConfig(paths []string, opts configOptions) (*MConfig, error) {
+	if len(paths) == 0 {
+		return nil, errors.New("layered config without any file")
+	}
+	if opts.flags == nil {
+		opts.flags = newFlagOverlay()
+	}
+	// schema校验的是合并之后的结果，单独的一层不需要校验
+	layerOpts := opts
+	layerOpts.schema = nil
+	layers := make([]*MConfig, 0, len(paths))
+	for _, p := range paths {
+		layer, err := newMConfig(p, layerOpts)
+		if err != nil {
+			return nil, err
+		}
+		layers = append(layers, layer)
+	}
+
+	// 路径相关的信息使用优先级最高的一层
+	top := layers[len(layers)-1]
+	cf := &MConfig{
+		rawEntryMap: make(map[string]json.RawMessage, 0),
+		dir:         top.dir,
+		filename:    top.filename,
+		path:        top.path,
+		format:      top.format,
+		opts:        opts,
+		layers:      layers,
+	}
+	for _, layer := range layers {
+		if err := mergeRawMap(cf.rawEntryMap, layer.rawEntryMap, opts.arrayMerge); err != nil {
+			return nil, fmt.Errorf("merge config file:%s failed, error:%s", layer.path, err.Error())
+		}
+	}
+	root, err := buildTree(cf.rawEntryMap)
+	if err != nil {
+		return nil, err
+	}
+	markLayerDatetimes(root, layers)
+	cf.root = root
+	return cf, nil
+}
+
+/*
+ * 把src合并到dst中，两边都是对象时递归合并，都是数组时按照mode处理，其他情况src覆盖dst
+ */
+func mergeRawMap(dst map[string]json.RawMessage, src map[string]json.RawMessage, mode ArrayMergeMode) error {
+	for key, srcVal := range src {
+		dstVal, ok := dst[key]
+		if !ok {
+			dst[key] = srcVal
+			continue
+		}
+		merged, err := mergeRaw(dstVal, srcVal, mode)
+		if err != nil {
+			return err
+		}
+		dst[key] = merged
+	}
+	return nil
+}
+
+func mergeRaw(dst json.RawMessage, src json.RawMessage, mode ArrayMergeMode) (json.RawMessage, error) {
+	dstKind, srcKind := rawKind(dst), rawKind(src)
+	if dstKind == '{' && srcKind == '{' {
+		var dstMap, srcMap map[string]json.RawMessage
+		if err := json.Unmarshal(dst, &dstMap); err != nil {
+			return nil, err
+		}
+		if err := json.Unmarshal(src, &srcMap); err != nil {
+			return nil, err
+		}
+		if err := mergeRawMap(dstMap, srcMap, mode); err != nil {
+			return nil, err
+		}
+		return json.Marshal(dstMap)
+	}
+	if dstKind == '[' && srcKind == '[' && mode == ArrayAppend {
+		var dstSlice, srcSlice []json.RawMessage
+		if err := json.Unmarshal(dst, &dstSlice); err != nil {
+			return nil, err
+		}
+		if err := json.Unmarshal(src, &srcSlice); err != nil {
+			return nil, err
+		}
+		return json.Marshal(append(dstSlice, srcSlice...))
+	}
+	return src, nil
+}
+
+/*
+ * json片段的类型，对象返回'{'，数组返回'['，其他返回第一个非空白字符
+ */
+func rawKind(raw json.RawMessage) byte {
+	raw = bytes.TrimLeft(raw, " \t\r\n")
+	if len(raw) == 0 {
+		return 0
+	}
+	return raw[0]
+}
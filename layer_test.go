@@ -0,0 +1,86 @@
+package conf
+
+import (
+	"conf/fileutil"
+	"path/filepath"
+	"testing"
+)
+
+func TestLayeredConfig(t *testing.T) {
+	SetLayeredConfig("layered", []string{"testdir/layer_base.json", "testdir/layer_prod.yaml"}, nil)
+	conf := MultiConfig("layered")
+
+	val, err := conf.GetString("db.host")
+	if err != nil || val != "db.prod" {
+		t.Errorf("GetString(%s) = %s; expected %s, error:%+v", "db.host", val, "db.prod", err)
+	}
+
+	// 对象深度合并，没有被覆盖的值保留
+	val2, err := conf.GetInt("db.port")
+	if err != nil || val2 != 3306 {
+		t.Errorf("GetInt(%s) = %d; expected %d, error:%+v", "db.port", val2, 3306, err)
+	}
+	val3, err := conf.GetInt("db.pool.size")
+	if err != nil || val3 != 50 {
+		t.Errorf("GetInt(%s) = %d; expected %d, error:%+v", "db.pool.size", val3, 50, err)
+	}
+	val4, err := conf.GetInt("db.pool.idle")
+	if err != nil || val4 != 2 {
+		t.Errorf("GetInt(%s) = %d; expected %d, error:%+v", "db.pool.idle", val4, 2, err)
+	}
+
+	// 数组默认替换
+	val5, err := conf.GetStringSlice("hosts")
+	if err != nil || len(val5) != 1 || val5[0] != "c" {
+		t.Errorf("GetStringSlice(%s) = %v; expected [c], error:%+v", "hosts", val5, err)
+	}
+
+	if conf.path != "testdir/layer_prod.yaml" {
+		t.Errorf("layered config path = %s; expected %s", conf.path, "testdir/layer_prod.yaml")
+	}
+}
+
+func TestLayeredConfigArrayAppend(t *testing.T) {
+	SetLayeredConfig("layered_append", []string{"testdir/layer_base.json", "testdir/layer_prod.yaml"}, nil, WithArrayMerge(ArrayAppend))
+	val, err := MultiConfig("layered_append").GetStringSlice("hosts")
+	if err != nil || len(val) != 3 || val[0] != "a" || val[2] != "c" {
+		t.Errorf("GetStringSlice(%s) = %v; expected [a b c], error:%+v", "hosts", val, err)
+	}
+}
+
+func TestLayeredConfigReload(t *testing.T) {
+	dir := t.TempDir()
+	base := filepath.Join(dir, "base.json")
+	local := filepath.Join(dir, "local.json")
+	fileutil.WriteContent(base, `{"db": {"host": "127.0.0.1", "port": 3306}}`)
+	fileutil.WriteContent(local, `{"db": {"port": 3307}}`)
+
+	conf, err := newLayeredMConfig([]string{base, local}, configOptions{})
+	if err != nil {
+		t.Fatalf("newLayeredMConfig error:%+v", err)
+	}
+	if conf.checkFileDiff() {
+		t.Errorf("checkFileDiff should be false before any change")
+	}
+
+	fileutil.WriteContent(base, `{"db": {"host": "10.0.0.1", "port": 3306}}`)
+	if !conf.checkFileDiff() {
+		t.Fatalf("checkFileDiff should detect change in a lower layer")
+	}
+	updated, err := conf.reload()
+	if err != nil {
+		t.Fatalf("reload error:%+v", err)
+	}
+	val, err := updated.GetString("db.host")
+	if err != nil || val != "10.0.0.1" {
+		t.Errorf("GetString(%s) = %s; expected %s, error:%+v", "db.host", val, "10.0.0.1", err)
+	}
+	val2, err := updated.GetInt("db.port")
+	if err != nil || val2 != 3307 {
+		t.Errorf("GetInt(%s) = %d; expected %d, error:%+v", "db.port", val2, 3307, err)
+	}
+
+	if _, err := newLayeredMConfig([]string{base, filepath.Join(dir, "missing.json")}, configOptions{}); err == nil {
+		t.Errorf("newLayeredMConfig with missing layer should fail")
+	}
+}
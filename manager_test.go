@@ -0,0 +1,195 @@
+package conf
+
+import (
+	"conf/fileutil"
+	"context"
+	"flag"
+	"fmt"
+	"path/filepath"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+)
+
+func waitInt(conf func() *MConfig, key string, expected int) (int, error) {
+	deadline := time.Now().Add(3 * time.Second)
+	for {
+		val, err := conf().GetInt(key)
+		if (err == nil && val == expected) || time.Now().After(deadline) {
+			return val, err
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+}
+
+func TestNewManager(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "manager.json")
+	fileutil.WriteContent(path, `{"key1": 1}`)
+	m := NewManager(WithInterval(100 * time.Millisecond))
+	if err := m.SetConfig("manager", path, nil); err != nil {
+		t.Fatalf("SetConfig error:%+v", err)
+	}
+	if MultiConfig("manager") != emptyConfig {
+		t.Errorf("config of a new manager should not be visible from the default manager")
+	}
+	if m.Config() != m.MultiConfig("manager") {
+		t.Errorf("Config() should return the only config of the manager")
+	}
+	conf := func() *MConfig { return m.MultiConfig("manager") }
+
+	m.Start()
+	m.Start()
+	fileutil.WriteContent(path, `{"key1": 2}`)
+	if val, err := waitInt(conf, "key1", 2); err != nil || val != 2 {
+		t.Errorf("GetInt(%s) = %d; expected %d, error:%+v", "key1", val, 2, err)
+	}
+
+	// 停止之后不再更新
+	m.Stop()
+	m.Stop()
+	fileutil.WriteContent(path, `{"key1": 3}`)
+	time.Sleep(300 * time.Millisecond)
+	if val, err := conf().GetInt("key1"); err != nil || val != 2 {
+		t.Errorf("GetInt(%s) = %d; expected %d after Stop, error:%+v", "key1", val, 2, err)
+	}
+
+	// 重新启动之后可以感知停止期间的变化
+	m.Start()
+	defer m.Stop()
+	if val, err := waitInt(conf, "key1", 3); err != nil || val != 3 {
+		t.Errorf("GetInt(%s) = %d; expected %d after restart, error:%+v", "key1", val, 3, err)
+	}
+	if status, ok := m.Status("manager"); !ok || status.LastLoad.IsZero() {
+		t.Errorf("Status = %+v; expected a successful load", status)
+	}
+}
+
+func TestReplaceConfig(t *testing.T) {
+	dir := t.TempDir()
+	pathA := filepath.Join(dir, "a.json")
+	pathB := filepath.Join(dir, "b.json")
+	fileutil.WriteContent(pathA, `{"key1": 1, "key2": "a"}`)
+	fileutil.WriteContent(pathB, `{"key1": 2, "key3": "b"}`)
+	m := NewManager(WithInterval(100 * time.Millisecond))
+	var called []string
+	var lock sync.Mutex
+	record := func(tag string) func(string) {
+		return func(name string) {
+			lock.Lock()
+			called = append(called, tag+":"+name)
+			lock.Unlock()
+		}
+	}
+	m.SetConfig("replace", pathA, record("a"))
+	// 已经存在的配置SetConfig不做任何事
+	m.SetConfig("replace", pathB, record("b"))
+	if val, _ := m.MultiConfig("replace").GetInt("key1"); val != 1 {
+		t.Errorf("GetInt(%s) = %d; expected %d", "key1", val, 1)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	events := m.Watch(ctx, "replace")
+	if err := m.ReplaceConfig("replace", filepath.Join(dir, "not_exist.json"), nil); err == nil {
+		t.Errorf("ReplaceConfig with a missing file should fail")
+	}
+	if err := m.ReplaceConfig("replace", pathB, record("b")); err != nil {
+		t.Fatalf("ReplaceConfig error:%+v", err)
+	}
+	if val, _ := m.MultiConfig("replace").GetInt("key1"); val != 2 {
+		t.Errorf("GetInt(%s) = %d; expected %d", "key1", val, 2)
+	}
+	event := <-events
+	if !reflect.DeepEqual(event.Added, []string{"key3"}) || !reflect.DeepEqual(event.Removed, []string{"key2"}) ||
+		!reflect.DeepEqual(event.Modified, []string{"key1"}) {
+		t.Errorf("event = %+v; expected key3 added, key2 removed, key1 modified", event)
+	}
+
+	// 原来的文件不再监听，新的文件变化时调用新的回调
+	m.Start()
+	defer m.Stop()
+	fileutil.WriteContent(pathA, `{"key1": 10}`)
+	fileutil.WriteContent(pathB, `{"key1": 20}`)
+	conf := func() *MConfig { return m.MultiConfig("replace") }
+	if val, err := waitInt(conf, "key1", 20); err != nil || val != 20 {
+		t.Errorf("GetInt(%s) = %d; expected %d, error:%+v", "key1", val, 20, err)
+	}
+	// 替换和重新加载各调用一次新的回调
+	lock.Lock()
+	if !reflect.DeepEqual(called, []string{"b:replace", "b:replace"}) {
+		t.Errorf("called = %v; expected only the new callback", called)
+	}
+	lock.Unlock()
+	<-events
+
+	if !m.RemoveConfig("replace") {
+		t.Errorf("RemoveConfig = false; expected true")
+	}
+	if m.MultiConfig("replace") != emptyConfig {
+		t.Errorf("removed config should not be visible")
+	}
+	if event := <-events; !reflect.DeepEqual(event.Removed, []string{"key1"}) || event.New != emptyConfig {
+		t.Errorf("event = %+v; expected all keys removed", event)
+	}
+	if m.RemoveConfig("replace") {
+		t.Errorf("RemoveConfig of a removed config = true; expected false")
+	}
+	if _, ok := m.Status("replace"); ok {
+		t.Errorf("Status of a removed config should return false")
+	}
+}
+
+func TestReplaceConfigKeepFlags(t *testing.T) {
+	dir := t.TempDir()
+	pathA := filepath.Join(dir, "a.json")
+	pathB := filepath.Join(dir, "b.json")
+	fileutil.WriteContent(pathA, `{"port": 1}`)
+	fileutil.WriteContent(pathB, `{"port": 2, "host": "b"}`)
+	m := NewManager()
+	m.SetConfig("flags", pathA, nil)
+
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	if err := m.MultiConfig("flags").BindFlags(fs, "port"); err != nil {
+		t.Fatalf("BindFlags error:%+v", err)
+	}
+	if err := fs.Parse([]string{"-port", "99"}); err != nil {
+		t.Fatalf("Parse error:%+v", err)
+	}
+	if err := m.ReplaceConfig("flags", pathB, nil); err != nil {
+		t.Fatalf("ReplaceConfig error:%+v", err)
+	}
+	// 替换之后参数仍然覆盖配置文件中的值
+	if val, err := m.MultiConfig("flags").GetInt("port"); err != nil || val != 99 {
+		t.Errorf("GetInt(%s) = %d; expected %d, error:%+v", "port", val, 99, err)
+	}
+	if val, err := m.MultiConfig("flags").GetString("host"); err != nil || val != "b" {
+		t.Errorf("GetString(%s) = %s; expected %s, error:%+v", "host", val, "b", err)
+	}
+}
+
+func TestConcurrentRegistration(t *testing.T) {
+	dir := t.TempDir()
+	m := NewManager(WithInterval(10 * time.Millisecond))
+	m.Start()
+	defer m.Stop()
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		path := filepath.Join(dir, fmt.Sprintf("concurrent%d.json", i))
+		fileutil.WriteContent(path, fmt.Sprintf(`{"key1": %d}`, i))
+		wg.Add(1)
+		go func(i int, path string) {
+			defer wg.Done()
+			name := fmt.Sprintf("concurrent%d", i%2)
+			for j := 0; j < 20; j++ {
+				m.SetConfig(name, path, func(string) {})
+				m.ReplaceConfig(name, path, func(string) {})
+				m.checkUpdate()
+				if j%5 == 0 {
+					m.RemoveConfig(name)
+				}
+			}
+		}(i, path)
+	}
+	wg.Wait()
+}
@@ -0,0 +1,391 @@
+package conf
+
+import (
+	"bytes"
+	"encoding/json"
+	"strconv"
+	"strings"
+)
+
+/*
+ * 加载时一次性把配置解析成的节点树，加载之后不再修改，可以并发读取
+ * Get*方法直接在树上查找和转换，不需要每一级都重新json.Unmarshal
+ */
+type nodeKind uint8
+
+const (
+	nullNode nodeKind = iota
+	boolNode
+	numberNode
+	stringNode
+	arrayNode
+	objectNode
+)
+
+type node struct {
+	kind nodeKind
+	// 字符串的值，数字保留原始的写法
+	str     string
+	boolean bool
+	// toml原生的日期时间，str中保存按照种类格式化之后的值
+	datetime DatetimeKind
+	items    []*node
+	fields   map[string]*node
+	// 节点对应的原始json片段，GetRawMessage、Unmarshal使用
+	raw json.RawMessage
+}
+
+/*
+ * 使用rawEntryMap建立节点树，根节点是对象
+ */
+func buildTree(rawMap map[string]json.RawMessage) (*node, error) {
+	root := &node{kind: objectNode, fields: make(map[string]*node, len(rawMap))}
+	for key, raw := range rawMap {
+		child, err := parseNode(raw)
+		if err != nil {
+			return nil, err
+		}
+		root.fields[key] = child
+	}
+	return root, nil
+}
+
+func parseNode(raw []byte) (*node, error) {
+	dec := json.NewDecoder(bytes.NewReader(raw))
+	dec.UseNumber()
+	return decodeNode(dec, raw)
+}
+
+/*
+ * 按照token递归建立节点，每个节点的raw直接引用原始内容中对应的部分
+ */
+func decodeNode(dec *json.Decoder, data []byte) (*node, error) {
+	start := dec.InputOffset()
+	tok, err := dec.Token()
+	if err != nil {
+		return nil, err
+	}
+	n := &node{}
+	switch v := tok.(type) {
+	case json.Delim:
+		if v == '{' {
+			n.kind = objectNode
+			n.fields = make(map[string]*node, 0)
+			for dec.More() {
+				keyTok, err := dec.Token()
+				if err != nil {
+					return nil, err
+				}
+				child, err := decodeNode(dec, data)
+				if err != nil {
+					return nil, err
+				}
+				n.fields[keyTok.(string)] = child
+			}
+		} else {
+			n.kind = arrayNode
+			n.items = make([]*node, 0)
+			for dec.More() {
+				child, err := decodeNode(dec, data)
+				if err != nil {
+					return nil, err
+				}
+				n.items = append(n.items, child)
+			}
+		}
+		// 结束的}或者]
+		if _, err := dec.Token(); err != nil {
+			return nil, err
+		}
+	case string:
+		n.kind = stringNode
+		n.str = v
+	case json.Number:
+		n.kind = numberNode
+		n.str = string(v)
+	case bool:
+		n.kind = boolNode
+		n.boolean = v
+	case nil:
+		n.kind = nullNode
+	}
+	// 开始的位置在上一个token之后，需要去掉中间的分隔符
+	raw := bytes.TrimLeft(data[start:dec.InputOffset()], " \t\r\n,:")
+	n.raw = json.RawMessage(raw[:len(raw):len(raw)])
+	return n, nil
+}
+
+/*
+ * 命令行参数和环境变量的值作为字符串节点
+ */
+func stringValueNode(s string) *node {
+	raw, _ := json.Marshal(s)
+	return &node{kind: stringNode, str: s, raw: raw}
+}
+
+/*
+ * 按照key逐级查找节点，例如key10.key11[0].key12
+ * 不拆分key，查找的过程中不分配内存
+ */
+func (n *node) find(key string) (*node, error) {
+	current := n
+	scanner := newKeyScanner(key)
+	for {
+		elem, ok := scanner.next()
+		if !ok {
+			break
+		}
+		var err error
+		if current, err = current.child(elem); err != nil {
+			return nil, err
+		}
+	}
+	if scanner.err != nil {
+		return nil, scanner.err
+	}
+	return current, nil
+}
+
+/*
+ * 按照已经解析过的path逐级查找节点
+ */
+func (n *node) findPath(path Path) (*node, error) {
+	current := n
+	for _, elem := range path {
+		var err error
+		if current, err = current.child(elem); err != nil {
+			return nil, err
+		}
+	}
+	return current, nil
+}
+
+/*
+ * 对象中key对应的子节点，或者数组中下标对应的元素
+ * 负数下标从数组的末尾开始，JSON Pointer中的数字在数组上按照下标处理
+ */
+func (n *node) child(elem PathElem) (*node, error) {
+	if n.kind == arrayNode {
+		index := elem.Index
+		if !elem.IsIndex {
+			var ok bool
+			if index, ok = arrayIndex(elem.Key); !ok {
+				return nil, TypeErr
+			}
+		}
+		if index < 0 {
+			index += len(n.items)
+		}
+		if index < 0 || index >= len(n.items) {
+			return nil, InvalidSliceIndexErr
+		}
+		return n.items[index], nil
+	}
+	if n.kind != objectNode || elem.IsIndex {
+		return nil, TypeErr
+	}
+	child, ok := n.fields[elem.Key]
+	if !ok {
+		return nil, KeyNotFoundErr
+	}
+	return child, nil
+}
+
+/*
+ * 以下方法把节点转换成Get*需要的类型，null转换成零值，和json.Unmarshal的行为一致
+ * weak为true时表示配置来源没有类型信息(例如ini)，字符串会按照目标类型进行转换
+ */
+/*
+ * 没有类型的配置中同时有值和子key的对象，按照值读取时使用leafKey中保存的值
+ */
+func (n *node) leaf(weak bool) *node {
+	if weak && n.kind == objectNode {
+		if value, ok := n.fields[leafKey]; ok {
+			return value
+		}
+	}
+	return n
+}
+
+func (n *node) toString(weak bool) (string, error) {
+	n = n.leaf(weak)
+	switch n.kind {
+	case stringNode:
+		return n.str, nil
+	case nullNode:
+		return "", nil
+	}
+	return "", TypeErr
+}
+
+/*
+ * 数字节点的字面量，没有类型时字符串按照数字处理，null当作0
+ */
+func (n *node) number(weak bool) (string, error) {
+	n = n.leaf(weak)
+	switch n.kind {
+	case numberNode:
+		return n.str, nil
+	case stringNode:
+		if weak {
+			return strings.TrimSpace(n.str), nil
+		}
+	case nullNode:
+		return "0", nil
+	}
+	return "", TypeErr
+}
+
+func (n *node) toInt(weak bool) (int, error) {
+	s, err := n.number(weak)
+	if err != nil {
+		return 0, err
+	}
+	intVal, err := parseInteger(s, strconv.IntSize)
+	return int(intVal), err
+}
+
+func (n *node) toInt64(weak bool) (int64, error) {
+	s, err := n.number(weak)
+	if err != nil {
+		return 0, err
+	}
+	return parseInteger(s, 64)
+}
+
+func (n *node) toUint64(weak bool) (uint64, error) {
+	s, err := n.number(weak)
+	if err != nil {
+		return 0, err
+	}
+	return parseUnsigned(s, 64)
+}
+
+func (n *node) toFloat(weak bool) (float32, error) {
+	s, err := n.number(weak)
+	if err != nil {
+		return 0, err
+	}
+	floatVal, err := parseFloat(s, 32)
+	return float32(floatVal), err
+}
+
+func (n *node) toFloat64(weak bool) (float64, error) {
+	s, err := n.number(weak)
+	if err != nil {
+		return 0, err
+	}
+	return parseFloat(s, 64)
+}
+
+/*
+ * 保留原始写法的数字，null返回空的json.Number
+ */
+func (n *node) toNumber(weak bool) (json.Number, error) {
+	n = n.leaf(weak)
+	if n.kind == nullNode {
+		return "", nil
+	}
+	s, err := n.number(weak)
+	if err != nil {
+		return "", err
+	}
+	if !isNumber(s) {
+		return "", TypeErr
+	}
+	return json.Number(s), nil
+}
+
+func (n *node) toBool(weak bool) (bool, error) {
+	n = n.leaf(weak)
+	switch n.kind {
+	case boolNode:
+		return n.boolean, nil
+	case stringNode:
+		if weak {
+			return strconv.ParseBool(strings.TrimSpace(n.str))
+		}
+	case nullNode:
+		return false, nil
+	}
+	return false, TypeErr
+}
+
+/*
+ * 数组节点的元素，没有类型的配置中数组可以写成逗号分隔的字符串，例如"1,2,3"
+ * null返回nil，和json.Unmarshal到slice的行为一致
+ */
+func (n *node) elems(weak bool) ([]*node, error) {
+	n = n.leaf(weak)
+	switch n.kind {
+	case arrayNode:
+		return n.items, nil
+	case nullNode:
+		return nil, nil
+	case stringNode:
+		if weak {
+			return splitWeakNodes(n.str), nil
+		}
+	}
+	return nil, TypeErr
+}
+
+func splitWeakNodes(strVal string) []*node {
+	rawSliceVal := splitWeakSlice(strVal)
+	nodes := make([]*node, 0, len(rawSliceVal))
+	for _, raw := range rawSliceVal {
+		if item, err := parseNode(raw); err == nil {
+			nodes = append(nodes, item)
+		}
+	}
+	return nodes
+}
+
+/*
+ * 把数组节点的每个元素按照convert转换，逗号分隔的字符串拆出来的元素同样没有类型
+ */
+func convertElems[T any](n *node, weak bool, convert func(item *node, weak bool) (T, error)) ([]T, error) {
+	items, err := n.elems(weak)
+	if err != nil || items == nil {
+		return nil, err
+	}
+	sliceVal := make([]T, len(items))
+	for i, item := range items {
+		if sliceVal[i], err = convert(item, weak || n.kind == stringNode); err != nil {
+			return nil, err
+		}
+	}
+	return sliceVal, nil
+}
+
+func (n *node) toStringSlice(weak bool) ([]string, error) {
+	return convertElems(n, weak, (*node).toString)
+}
+
+func (n *node) toIntSlice(weak bool) ([]int, error) {
+	return convertElems(n, weak, (*node).toInt)
+}
+
+func (n *node) toInt64Slice(weak bool) ([]int64, error) {
+	return convertElems(n, weak, (*node).toInt64)
+}
+
+func (n *node) toUint64Slice(weak bool) ([]uint64, error) {
+	return convertElems(n, weak, (*node).toUint64)
+}
+
+func (n *node) toFloatSlice(weak bool) ([]float32, error) {
+	return convertElems(n, weak, (*node).toFloat)
+}
+
+func (n *node) toFloat64Slice(weak bool) ([]float64, error) {
+	return convertElems(n, weak, (*node).toFloat64)
+}
+
+func (n *node) toNumberSlice(weak bool) ([]json.Number, error) {
+	return convertElems(n, weak, (*node).toNumber)
+}
+
+func (n *node) toBoolSlice(weak bool) ([]bool, error) {
+	return convertElems(n, weak, (*node).toBool)
+}
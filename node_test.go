@@ -0,0 +1,220 @@
+package conf
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestNodeTree(t *testing.T) {
+	rawMap, err := decodeJSON([]byte(`{
+		"key10": {"key11": [{"key12": "value12"}, {"key13": [1, 2.5, null]}]},
+		"empty": {},
+		"nil": null
+	}`))
+	if err != nil {
+		t.Fatalf("decodeJSON error:%+v", err)
+	}
+	root, err := buildTree(rawMap)
+	if err != nil {
+		t.Fatalf("buildTree error:%+v", err)
+	}
+	cases := map[string]string{
+		"key10.key11[0].key12": `"value12"`,
+		"key10.key11[1]":       `{"key13": [1, 2.5, null]}`,
+		"key10.key11[1].key13": `[1, 2.5, null]`,
+		"empty":                `{}`,
+		"nil":                  `null`,
+	}
+	for key, expected := range cases {
+		n, err := root.find(key)
+		if err != nil || string(n.raw) != expected {
+			t.Errorf("find(%s).raw = %s; expected %s, error:%+v", key, n.raw, expected, err)
+		}
+	}
+	errCases := map[string]error{
+		"key10.key11[2].key12":   InvalidSliceIndexErr,
+		"key10.key12":            KeyNotFoundErr,
+		"key10.key11[0].key12.a": TypeErr,
+		"key10..key11":           InvalidKeyErr,
+	}
+	for key, expected := range errCases {
+		if _, err := root.find(key); err != expected {
+			t.Errorf("find(%s) error = %+v; expected %+v", key, err, expected)
+		}
+	}
+
+	n, _ := root.find("key10.key11[1].key13")
+	if _, err := n.toIntSlice(false); err != TypeErr {
+		t.Errorf("toIntSlice([1, 2.5, null]) error = %+v; expected %+v", err, TypeErr)
+	}
+	floats, err := n.toFloatSlice(false)
+	if err != nil || len(floats) != 3 || floats[1] != 2.5 || floats[2] != 0 {
+		t.Errorf("toFloatSlice([1, 2.5, null]) = %v; expected [1 2.5 0], error:%+v", floats, err)
+	}
+}
+
+/*
+ * 生成测试文档，deep为嵌套depth层的对象，wide为一个对象下有width个key，每个key下是数组
+ */
+func deepDocument(depth int) (map[string]json.RawMessage, string) {
+	doc := `"value"`
+	keys := make([]string, depth)
+	for i := depth - 1; i >= 0; i-- {
+		doc = fmt.Sprintf(`{"key%d": [%s, {"pad": [1, 2, 3]}]}`, i, doc)
+		keys[i] = fmt.Sprintf("key%d[0]", i)
+	}
+	rawMap, _ := decodeJSON([]byte(doc))
+	return rawMap, strings.TrimSuffix(strings.Join(keys, "."), "[0]")
+}
+
+func wideDocument(width int) map[string]json.RawMessage {
+	fields := make([]string, width)
+	for i := 0; i < width; i++ {
+		fields[i] = fmt.Sprintf(`"key%d": [{"name": "item%d", "port": %d, "tags": ["a", "b", "c"]}]`, i, i, i)
+	}
+	rawMap, _ := decodeJSON([]byte("{" + strings.Join(fields, ",") + "}"))
+	return rawMap
+}
+
+/*
+ * 解析key中的一个单元，返回去掉空格的key和数组下标，没有下标时返回-1
+ * 例如 "key11[ 0 ]" 返回 "key11", 0
+ */
+func parseElem(elem string) (string, int, error) {
+	index := -1
+	if elem[len(elem)-1] == ']' {
+		boundary := strings.LastIndexByte(elem, '[')
+		if boundary < 0 {
+			return "", -1, InvalidKeyErr
+		}
+		var err error
+		index, err = strconv.Atoi(strings.Replace(elem[boundary+1:len(elem)-1], " ", "", -1))
+		if err != nil {
+			return "", -1, InvalidKeyErr
+		}
+		if index < 0 {
+			return "", -1, InvalidSliceIndexErr
+		}
+		// 取出真正的key
+		elem = elem[0:boundary]
+	}
+	return strings.Trim(elem, " "), index, nil
+}
+
+/*
+ * 改成节点树之前的实现，每一级都重新json.Unmarshal，只用来做性能对比
+ */
+func legacyTravel(rawMap map[string]json.RawMessage, key string) (json.RawMessage, error) {
+	elems := strings.Split(key, ".")
+	for i, elem := range elems {
+		elem, index, err := parseElem(elem)
+		if err != nil {
+			return nil, err
+		}
+		val, ok := rawMap[elem]
+		if !ok {
+			return nil, KeyNotFoundErr
+		}
+		if index >= 0 {
+			var rawSlice []json.RawMessage
+			if err := json.Unmarshal(val, &rawSlice); err != nil {
+				return nil, err
+			}
+			if len(rawSlice) <= index {
+				return nil, InvalidSliceIndexErr
+			}
+			val = rawSlice[index]
+		}
+		if i == len(elems)-1 {
+			return val, nil
+		}
+		if err := json.Unmarshal(val, &rawMap); err != nil {
+			return nil, err
+		}
+	}
+	return nil, KeyNotFoundErr
+}
+
+func benchmarkLookup(b *testing.B, rawMap map[string]json.RawMessage, key string) {
+	root, err := buildTree(rawMap)
+	if err != nil {
+		b.Fatalf("buildTree error:%+v", err)
+	}
+	b.Run("tree", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			if _, err := root.find(key); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+	b.Run("legacy", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			if _, err := legacyTravel(rawMap, key); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+}
+
+func BenchmarkLookupDeep(b *testing.B) {
+	rawMap, key := deepDocument(20)
+	benchmarkLookup(b, rawMap, key)
+}
+
+func BenchmarkLookupWide(b *testing.B) {
+	benchmarkLookup(b, wideDocument(1000), "key500[0].port")
+}
+
+/*
+ * 对比Get*的完整路径：cached为命中缓存，tree为节点树查找并转换，legacy为逐层Unmarshal
+ */
+func BenchmarkGetInt(b *testing.B) {
+	const key = "key500[0].port"
+	rawMap := wideDocument(1000)
+	root, err := buildTree(rawMap)
+	if err != nil {
+		b.Fatalf("buildTree error:%+v", err)
+	}
+	conf := &MConfig{root: root, rawEntryMap: rawMap, opts: configOptions{flags: newFlagOverlay()}}
+	b.Run("cached", func(b *testing.B) {
+		b.ReportAllocs()
+		b.ResetTimer()
+		for i := 0; i < b.N; i++ {
+			if _, err := conf.GetInt(key); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+	b.Run("tree", func(b *testing.B) {
+		b.ReportAllocs()
+		b.ResetTimer()
+		for i := 0; i < b.N; i++ {
+			n, err := root.find(key)
+			if err != nil {
+				b.Fatal(err)
+			}
+			if _, err := n.toInt(false); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+	b.Run("legacy", func(b *testing.B) {
+		b.ReportAllocs()
+		b.ResetTimer()
+		for i := 0; i < b.N; i++ {
+			raw, err := legacyTravel(rawMap, key)
+			if err != nil {
+				b.Fatal(err)
+			}
+			var val int
+			if err := json.Unmarshal(raw, &val); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+}
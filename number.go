@@ -0,0 +1,135 @@
+package conf
+
+import (
+	"encoding/json"
+	"errors"
+	"math/big"
+	"strconv"
+	"strings"
+)
+
+var OutOfRangeErr = errors.New("value out of range")
+
+// 64位整数最多20位，有效数字加上指数超过这个长度时一定超出范围
+const maxIntegerDigits = 20
+
+/*
+ * 把数字的字面量转换成bitSize位的整数
+ * 1e3、3.0这类值为整数的写法也可以转换，有小数部分时返回TypeErr，超出范围时返回OutOfRangeErr
+ */
+func parseInteger(s string, bitSize int) (int64, error) {
+	intVal, err := strconv.ParseInt(s, 10, bitSize)
+	if err == nil {
+		return intVal, nil
+	}
+	// 1e3这类写法以及超出范围的值由parseIntegral判断，ParseInt在数字很长时不看指数就会返回ErrRange
+	i, err := parseIntegral(s)
+	if err != nil {
+		return 0, err
+	}
+	if !i.IsInt64() {
+		return 0, OutOfRangeErr
+	}
+	intVal = i.Int64()
+	if bitSize < 64 && (intVal < -1<<(bitSize-1) || intVal > 1<<(bitSize-1)-1) {
+		return 0, OutOfRangeErr
+	}
+	return intVal, nil
+}
+
+/*
+ * 和parseInteger相同，负数返回OutOfRangeErr
+ */
+func parseUnsigned(s string, bitSize int) (uint64, error) {
+	uintVal, err := strconv.ParseUint(s, 10, bitSize)
+	if err == nil {
+		return uintVal, nil
+	}
+	i, err := parseIntegral(s)
+	if err != nil {
+		return 0, err
+	}
+	if !i.IsUint64() {
+		return 0, OutOfRangeErr
+	}
+	uintVal = i.Uint64()
+	if bitSize < 64 && uintVal > 1<<bitSize-1 {
+		return 0, OutOfRangeErr
+	}
+	return uintVal, nil
+}
+
+/*
+ * 直接在字面量上判断是否为整数，例如1e3、3.0、-0.5e1，不经过浮点数，不会因为舍入丢掉小数部分
+ * 有效数字去掉末尾的0之后，小数点移动的位数(指数减去小数的位数)为负数时有小数部分
+ */
+func parseIntegral(s string) (*big.Int, error) {
+	if !isNumber(s) {
+		return nil, TypeErr
+	}
+	negative := s[0] == '-'
+	if negative {
+		s = s[1:]
+	}
+	mantissa, expPart := s, ""
+	if i := strings.IndexAny(s, "eE"); i >= 0 {
+		mantissa, expPart = s[:i], s[i+1:]
+	}
+	intPart, fracPart := mantissa, ""
+	if i := strings.IndexByte(mantissa, '.'); i >= 0 {
+		intPart, fracPart = mantissa[:i], mantissa[i+1:]
+	}
+	digits := strings.TrimLeft(intPart+fracPart, "0")
+	trimmed := strings.TrimRight(digits, "0")
+	if trimmed == "" {
+		return new(big.Int), nil
+	}
+	exp := 0
+	if expPart != "" {
+		var err error
+		if exp, err = strconv.Atoi(expPart); err != nil {
+			// 指数超出int的范围，非0的值不是太大就是有小数部分
+			if expPart[0] == '-' {
+				return nil, TypeErr
+			}
+			return nil, OutOfRangeErr
+		}
+	}
+	// 有效数字的末尾是个位时，小数点还需要移动的位数
+	shift := exp - len(fracPart) + len(digits) - len(trimmed)
+	if shift < 0 {
+		return nil, TypeErr
+	}
+	if len(trimmed)+shift > maxIntegerDigits {
+		return nil, OutOfRangeErr
+	}
+	i, _ := new(big.Int).SetString(trimmed+strings.Repeat("0", shift), 10)
+	if negative {
+		i.Neg(i)
+	}
+	return i, nil
+}
+
+/*
+ * 把数字的字面量转换成bitSize位的浮点数，超出范围时返回OutOfRangeErr
+ */
+func parseFloat(s string, bitSize int) (float64, error) {
+	floatVal, err := strconv.ParseFloat(s, bitSize)
+	if err == nil {
+		return floatVal, nil
+	}
+	if errors.Is(err, strconv.ErrRange) {
+		return 0, OutOfRangeErr
+	}
+	return 0, TypeErr
+}
+
+/*
+ * s是否为json的数字，例如 -1.5e3
+ */
+func isNumber(s string) bool {
+	if s == "" || (s[0] != '-' && (s[0] < '0' || s[0] > '9')) {
+		return false
+	}
+	return json.Valid([]byte(s))
+}
@@ -0,0 +1,147 @@
+package conf
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func TestNumberGetters(t *testing.T) {
+	m := NewManager()
+	if err := m.SetConfig("number", "testdir/number.json", nil); err != nil {
+		t.Fatalf("SetConfig error:%+v", err)
+	}
+	conf := m.MultiConfig("number")
+
+	int64Cases := map[string]int64{"id": 9007199254740993, "exp": 1000, "negative": -42, "nil": 0}
+	for key, expected := range int64Cases {
+		val, err := conf.GetInt64(key)
+		if err != nil || val != expected {
+			t.Errorf("GetInt64(%s) = %d; expected %d, error:%+v", key, val, expected, err)
+		}
+	}
+	uintVal, err := conf.GetUint64("big")
+	if err != nil || uintVal != 18446744073709551615 {
+		t.Errorf("GetUint64(%s) = %d; expected %d, error:%+v", "big", uintVal, uint64(18446744073709551615), err)
+	}
+	floatVal, err := conf.GetFloat64("ratio")
+	if err != nil || floatVal != 0.1 {
+		t.Errorf("GetFloat64(%s) = %v; expected %v, error:%+v", "ratio", floatVal, 0.1, err)
+	}
+	numVal, err := conf.GetNumber("big")
+	if err != nil || numVal != "18446744073709551615" {
+		t.Errorf("GetNumber(%s) = %s; expected %s, error:%+v", "big", numVal, "18446744073709551615", err)
+	}
+	numVal, err = conf.GetNumber("huge")
+	if err != nil || numVal != "1e400" {
+		t.Errorf("GetNumber(%s) = %s; expected %s, error:%+v", "huge", numVal, "1e400", err)
+	}
+
+	ids, err := conf.GetInt64Slice("ids")
+	if err != nil || len(ids) != 2 || ids[0] != 9007199254740993 {
+		t.Errorf("GetInt64Slice(%s) = %v; expected [9007199254740993 1], error:%+v", "ids", ids, err)
+	}
+	ratios, err := conf.GetFloat64Slice("ratios")
+	if err != nil || len(ratios) != 2 || ratios[0] != 0.1 {
+		t.Errorf("GetFloat64Slice(%s) = %v; expected [0.1 0.25], error:%+v", "ratios", ratios, err)
+	}
+	nums, err := conf.GetNumberSlice("ratios")
+	if err != nil || len(nums) != 2 || nums[1] != json.Number("0.25") {
+		t.Errorf("GetNumberSlice(%s) = %v; expected [0.1 0.25], error:%+v", "ratios", nums, err)
+	}
+
+	errCases := []struct {
+		getter   string
+		key      string
+		get      func(key string) error
+		expected error
+	}{
+		{"GetInt64", "big", func(key string) error { _, err := conf.GetInt64(key); return err }, OutOfRangeErr},
+		{"GetInt64", "huge", func(key string) error { _, err := conf.GetInt64(key); return err }, OutOfRangeErr},
+		{"GetInt64", "fraction", func(key string) error { _, err := conf.GetInt64(key); return err }, TypeErr},
+		{"GetInt64", "tiny", func(key string) error { _, err := conf.GetInt64(key); return err }, TypeErr},
+		{"GetInt", "fraction", func(key string) error { _, err := conf.GetInt(key); return err }, TypeErr},
+		{"GetInt", "big", func(key string) error { _, err := conf.GetInt(key); return err }, OutOfRangeErr},
+		{"GetUint64", "negative", func(key string) error { _, err := conf.GetUint64(key); return err }, OutOfRangeErr},
+		{"GetUint64", "fraction", func(key string) error { _, err := conf.GetUint64(key); return err }, TypeErr},
+		{"GetFloat", "huge", func(key string) error { _, err := conf.GetFloat(key); return err }, OutOfRangeErr},
+		{"GetFloat64", "huge", func(key string) error { _, err := conf.GetFloat64(key); return err }, OutOfRangeErr},
+		{"GetNumber", "name", func(key string) error { _, err := conf.GetNumber(key); return err }, TypeErr},
+		{"GetUint64Slice", "ids", func(key string) error { _, err := conf.GetUint64Slice(key); return err }, nil},
+		{"GetInt64Slice", "ratios", func(key string) error { _, err := conf.GetInt64Slice(key); return err }, TypeErr},
+	}
+	for _, c := range errCases {
+		if err := c.get(c.key); err != c.expected {
+			t.Errorf("%s(%s) error = %+v; expected %+v", c.getter, c.key, err, c.expected)
+		}
+	}
+}
+
+func TestParseIntegral(t *testing.T) {
+	cases := map[string]int64{
+		"1e3":                      1000,
+		"3.0":                      3,
+		"-0.5e1":                   -5,
+		"120e-1":                   12,
+		"0.0e5":                    0,
+		"-0":                       0,
+		"1.50e2":                   150,
+		"0.001e3":                  1,
+		"9223372036854775807.0":    9223372036854775807,
+		"-9.223372036854775808e18": -9223372036854775808,
+	}
+	for s, expected := range cases {
+		val, err := parseInteger(s, 64)
+		if err != nil || val != expected {
+			t.Errorf("parseInteger(%s) = %d; expected %d, error:%+v", s, val, expected, err)
+		}
+	}
+
+	// 小数部分在很多个0之后，按照浮点数舍入之后就是整数
+	errCases := map[string]error{
+		"1." + strings.Repeat("0", 300) + "1":     TypeErr,
+		"1" + strings.Repeat("0", 400) + "1e-401": TypeErr,
+		"1.5":                     TypeErr,
+		"15e-1":                   TypeErr,
+		"1e-999999999":            TypeErr,
+		"1e-99999999999999999999": TypeErr,
+		"0e99999999999999999999":  nil,
+		"1e99999999999999999999":  OutOfRangeErr,
+		"9223372036854775808.0":   OutOfRangeErr,
+		"1e19":                    OutOfRangeErr,
+	}
+	for s, expected := range errCases {
+		if _, err := parseInteger(s, 64); err != expected {
+			t.Errorf("parseInteger(%s) error = %+v; expected %+v", s, err, expected)
+		}
+	}
+	if val, err := parseUnsigned("1.8446744073709551615e19", 64); err != nil || val != 18446744073709551615 {
+		t.Errorf("parseUnsigned(%s) = %d; expected %d, error:%+v", "1.8446744073709551615e19", val, uint64(18446744073709551615), err)
+	}
+	if _, err := parseUnsigned("-1e0", 64); err != OutOfRangeErr {
+		t.Errorf("parseUnsigned(%s) error = %+v; expected %+v", "-1e0", err, OutOfRangeErr)
+	}
+}
+
+func TestNumberGettersWeak(t *testing.T) {
+	m := NewManager()
+	if err := m.SetConfig("ini", "testdir/test.ini", nil); err != nil {
+		t.Fatalf("SetConfig error:%+v", err)
+	}
+	conf := m.MultiConfig("ini")
+
+	port, err := conf.GetUint64("db.port")
+	if err != nil || port != 3306 {
+		t.Errorf("GetUint64(%s) = %d; expected %d, error:%+v", "db.port", port, 3306, err)
+	}
+	timeout, err := conf.GetNumber("db.timeout")
+	if err != nil || timeout != "1.5" {
+		t.Errorf("GetNumber(%s) = %s; expected %s, error:%+v", "db.timeout", timeout, "1.5", err)
+	}
+	if _, err := conf.GetInt64("db.timeout"); err != TypeErr {
+		t.Errorf("GetInt64(%s) error = %+v; expected %+v", "db.timeout", err, TypeErr)
+	}
+	if _, err := conf.GetNumber("db.host"); err != TypeErr {
+		t.Errorf("GetNumber(%s) error = %+v; expected %+v", "db.host", err, TypeErr)
+	}
+}
@@ -0,0 +1,147 @@
+package conf
+
+import (
+	"os"
+	"strconv"
+	"strings"
+)
+
+// 配置项的来源
+type Source int
+
+const (
+	SourceFile Source = iota
+	SourceEnv
+	SourceFlag
+)
+
+func (s Source) String() string {
+	switch s {
+	case SourceFile:
+		return "file"
+	case SourceEnv:
+		return "env"
+	case SourceFlag:
+		return "flag"
+	}
+	return "source(" + strconv.Itoa(int(s)) + ")"
+}
+
+/*
+ * 使用环境变量覆盖配置文件中的值，环境变量的优先级高于文件
+ * key到环境变量名的映射见EnvName，例如前缀为APP时
+ * key10.key11[0].key12 对应 APP_KEY10__KEY11_0__KEY12
+ */
+func WithEnvOverlay(prefix string) ConfigOption {
+	return func(o *configOptions) {
+		o.envPrefix = prefix
+	}
+}
+
+/*
+ * 把key转换成环境变量名
+ * 每一级之间用两个下划线连接，数组下标用一个下划线拼在后面，字母转成大写，
+ * 环境变量名中不允许出现的字符替换成下划线
+ */
+func EnvName(prefix string, key string) (string, error) {
+	name := strings.Builder{}
+	if prefix != "" {
+		name.WriteString(prefix)
+		name.WriteString("_")
+	}
+	scanner := newKeyScanner(key)
+	for i := 0; ; i++ {
+		elem, ok := scanner.next()
+		if !ok {
+			break
+		}
+		if elem.IsIndex {
+			// 负数下标对应的元素随数组长度变化，没有对应的环境变量
+			if elem.Index < 0 {
+				return "", InvalidSliceIndexErr
+			}
+			name.WriteString("_")
+			name.WriteString(strconv.Itoa(elem.Index))
+			continue
+		}
+		if i > 0 {
+			name.WriteString("__")
+		}
+		for _, c := range strings.ToUpper(elem.Key) {
+			if (c >= 'A' && c <= 'Z') || (c >= '0' && c <= '9') || c == '_' {
+				name.WriteRune(c)
+			} else {
+				name.WriteByte('_')
+			}
+		}
+	}
+	if scanner.err != nil {
+		return "", scanner.err
+	}
+	return name.String(), nil
+}
+
+/*
+ * 查找key对应的环境变量，没有启用覆盖或者环境变量不存在时返回false
+ * 环境变量名按照key缓存，环境变量的值每次都重新读取
+ */
+func (m *MConfig) lookupEnv(key string) (string, bool) {
+	if m.opts.envPrefix == "" {
+		return "", false
+	}
+	var name string
+	if cached, ok := m.envNames.Load(key); ok {
+		name = cached.(string)
+	} else {
+		// 没有对应环境变量的key缓存空的名字
+		name, _ = EnvName(m.opts.envPrefix, key)
+		m.envNames.Store(key, name)
+	}
+	if name == "" {
+		return "", false
+	}
+	return os.LookupEnv(name)
+}
+
+/*
+ * 查找覆盖在配置文件之上的值，优先级为 命令行参数 > 环境变量
+ */
+func (m *MConfig) lookupOverlay(key string) (string, Source, bool) {
+	if val, ok := m.lookupFlag(key); ok {
+		return val, SourceFlag, true
+	}
+	if val, ok := m.lookupEnv(key); ok {
+		return val, SourceEnv, true
+	}
+	return "", SourceFile, false
+}
+
+/*
+ * 查看key对应的值来自哪里，key不存在时返回KeyNotFoundErr
+ */
+func (m *MConfig) Source(key string) (Source, error) {
+	key, err := m.resolveKey(key)
+	if err != nil {
+		return SourceFile, err
+	}
+	if _, source, ok := m.lookupOverlay(key); ok {
+		return source, nil
+	}
+	_, err = m.travel(key, func(n *node, weak bool) (interface{}, error) {
+		return nil, nil
+	})
+	return SourceFile, err
+}
+
+/*
+ * 只包含配置文件内容的视图，解析时不使用命令行参数和环境变量的覆盖，订阅比较文件的变化时使用
+ */
+func (m *MConfig) withoutOverlay() *MConfig {
+	return &MConfig{
+		format:      m.format,
+		layers:      m.layers,
+		rawEntryMap: m.rawEntryMap,
+		root:        m.root,
+		opts:        configOptions{flags: newFlagOverlay()},
+	}
+}
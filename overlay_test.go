@@ -0,0 +1,122 @@
+package conf
+
+import (
+	"testing"
+)
+
+func TestEnvName(t *testing.T) {
+	cases := map[string]string{
+		"key1":                 "APP_KEY1",
+		"key10.key11[0].key12": "APP_KEY10__KEY11_0__KEY12",
+		"key10 . key11[ 12 ]":  "APP_KEY10__KEY11_12",
+		"db.max-conn":          "APP_DB__MAX_CONN",
+	}
+	for key, expected := range cases {
+		name, err := EnvName("APP", key)
+		if err != nil || name != expected {
+			t.Errorf("EnvName(%s) = %s; expected %s, error:%+v", key, name, expected, err)
+		}
+	}
+	if _, err := EnvName("APP", "key1..key2"); err != InvalidKeyErr {
+		t.Errorf("EnvName with empty elem error = %+v; expected %+v", err, InvalidKeyErr)
+	}
+}
+
+func TestEnvOverlay(t *testing.T) {
+	SetConfig("env_overlay", "testdir/test.json", nil, WithEnvOverlay("APP"))
+	conf := MultiConfig("env_overlay")
+
+	val, err := conf.GetString("key10.key11[0].key12")
+	if err != nil || val != "value12" {
+		t.Errorf("GetString(%s) = %s; expected %s, error:%+v", "key10.key11[0].key12", val, "value12", err)
+	}
+	if source, err := conf.Source("key10.key11[0].key12"); err != nil || source != SourceFile {
+		t.Errorf("Source(%s) = %s; expected %s, error:%+v", "key10.key11[0].key12", source, SourceFile, err)
+	}
+
+	t.Setenv("APP_KEY10__KEY11_0__KEY12", "env_value12")
+	t.Setenv("APP_KEY1", "42")
+	t.Setenv("APP_KEY3", "false")
+	t.Setenv("APP_KEY8__KEY9", "4,5")
+
+	val, err = conf.GetString("key10.key11[0].key12")
+	if err != nil || val != "env_value12" {
+		t.Errorf("GetString(%s) = %s; expected %s, error:%+v", "key10.key11[0].key12", val, "env_value12", err)
+	}
+	if source, err := conf.Source("key10.key11[0].key12"); err != nil || source != SourceEnv {
+		t.Errorf("Source(%s) = %s; expected %s, error:%+v", "key10.key11[0].key12", source, SourceEnv, err)
+	}
+
+	// JSON Pointer中对应数组的数字按照下标查找环境变量
+	val, err = conf.GetString("/key10/key11/0/key12")
+	if err != nil || val != "env_value12" {
+		t.Errorf("GetString(%s) = %s; expected %s, error:%+v", "/key10/key11/0/key12", val, "env_value12", err)
+	}
+	if source, err := conf.Source("/key10/key11/0/key12"); err != nil || source != SourceEnv {
+		t.Errorf("Source(%s) = %s; expected %s, error:%+v", "/key10/key11/0/key12", source, SourceEnv, err)
+	}
+
+	val2, err := conf.GetInt("key1")
+	if err != nil || val2 != 42 {
+		t.Errorf("GetInt(%s) = %d; expected %d, error:%+v", "key1", val2, 42, err)
+	}
+
+	val3, err := conf.GetBool("key3")
+	if err != nil || val3 != false {
+		t.Errorf("GetBool(%s) = %t; expected %t, error:%+v", "key3", val3, false, err)
+	}
+
+	val4, err := conf.GetIntSlice("key8.key9")
+	if err != nil || len(val4) != 2 || val4[0] != 4 || val4[1] != 5 {
+		t.Errorf("GetIntSlice(%s) = %v; expected [4 5], error:%+v", "key8.key9", val4, err)
+	}
+
+	// 没有被覆盖的key仍然读取文件
+	val5, err := conf.GetString("key2")
+	if err != nil || val5 != "value2" {
+		t.Errorf("GetString(%s) = %s; expected %s, error:%+v", "key2", val5, "value2", err)
+	}
+
+	if _, err := conf.Source("not_exist"); err != KeyNotFoundErr {
+		t.Errorf("Source(%s) error = %+v; expected %+v", "not_exist", err, KeyNotFoundErr)
+	}
+}
+
+/*
+ * 启用环境变量覆盖之后，没有被覆盖的key命中缓存时不分配内存
+ */
+func TestEnvOverlayAllocs(t *testing.T) {
+	m := NewManager()
+	if err := m.SetConfig("env_allocs", "testdir/test.json", nil, WithEnvOverlay("ALLOCS")); err != nil {
+		t.Fatalf("SetConfig error:%+v", err)
+	}
+	conf := m.MultiConfig("env_allocs")
+	allocs := testing.AllocsPerRun(100, func() {
+		conf.GetInt("key1")
+		conf.GetString("key10.key11[0].key12")
+	})
+	if allocs != 0 {
+		t.Errorf("GetInt with env overlay allocs = %v; expected 0", allocs)
+	}
+
+	// 缓存的是环境变量名，值变化之后仍然生效
+	t.Setenv("ALLOCS_KEY1", "42")
+	if val, err := conf.GetInt("key1"); err != nil || val != 42 {
+		t.Errorf("GetInt(%s) = %d; expected %d, error:%+v", "key1", val, 42, err)
+	}
+}
+
+func BenchmarkGetIntEnvOverlay(b *testing.B) {
+	m := NewManager()
+	if err := m.SetConfig("env_bench", "testdir/test.json", nil, WithEnvOverlay("BENCH")); err != nil {
+		b.Fatalf("SetConfig error:%+v", err)
+	}
+	conf := m.MultiConfig("env_bench")
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := conf.GetInt("key1"); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
@@ -0,0 +1,145 @@
+package conf
+
+import (
+	"conf/fileutil"
+	"fmt"
+	"math/rand"
+	"os"
+	"time"
+)
+
+// 检查文件是否变化时使用的算法
+type HashAlgorithm int
+
+const (
+	// 每次计算文件内容的md5
+	HashMD5 HashAlgorithm = iota
+	// 每次计算文件内容的sha1
+	HashSHA1
+	// 先比较文件的修改时间和大小，都没有变化时认为文件没有变化，否则再计算md5
+	// 适合比较大的文件，但是修改时间和大小都不变的修改会被忽略
+	HashModTime
+)
+
+func (a HashAlgorithm) String() string {
+	switch a {
+	case HashMD5:
+		return "md5"
+	case HashSHA1:
+		return "sha1"
+	case HashModTime:
+		return "mtime"
+	}
+	return fmt.Sprintf("hash(%d)", int(a))
+}
+
+/*
+ * 计算文件内容的hash，HashModTime使用md5
+ */
+func (a HashAlgorithm) sum(path string) (string, error) {
+	if a == HashSHA1 {
+		return fileutil.HashFileSha1(path)
+	}
+	return fileutil.HashFileMd5(path)
+}
+
+/*
+ * 指定检查文件变化时使用的算法，默认为HashMD5
+ */
+func WithHashAlgorithm(alg HashAlgorithm) ConfigOption {
+	return func(o *configOptions) {
+		o.hash = alg
+	}
+}
+
+/*
+ * 是否监听配置文件的变化，默认监听，不监听的配置加载之后不会再重新加载
+ */
+func WithWatch(enabled bool) ConfigOption {
+	return func(o *configOptions) {
+		o.noWatch = !enabled
+	}
+}
+
+// StartMonitor的可选项
+type managerOptions struct {
+	// 轮询的间隔，使用inotify时为检查新增配置的间隔
+	interval time.Duration
+	// 每次间隔额外增加的随机时间，避免大量实例同时读取共享存储
+	jitter time.Duration
+	// 使用inotify时仍然定期检查文件的hash，NFS、FUSE这类文件系统可以添加监听但是收不到事件
+	safetyInterval time.Duration
+}
+
+type ManagerOption func(*managerOptions)
+
+/*
+ * 指定轮询的间隔，默认为monitorDuration秒，可以小于1秒
+ */
+func WithInterval(interval time.Duration) ManagerOption {
+	return func(o *managerOptions) {
+		if interval > 0 {
+			o.interval = interval
+		}
+	}
+}
+
+/*
+ * 每次轮询的间隔额外增加[0, jitter)的随机时间
+ */
+func WithJitter(jitter time.Duration) ManagerOption {
+	return func(o *managerOptions) {
+		if jitter > 0 {
+			o.jitter = jitter
+		}
+	}
+}
+
+/*
+ * 使用inotify监听时定期检查文件hash的间隔，默认为safetyDuration，
+ * 添加监听成功但是收不到事件的文件系统(例如NFS、FUSE)上最晚在这个间隔之后重新加载
+ */
+func WithSafetyInterval(interval time.Duration) ManagerOption {
+	return func(o *managerOptions) {
+		if interval > 0 {
+			o.safetyInterval = interval
+		}
+	}
+}
+
+func newManagerOptions(opts []ManagerOption) managerOptions {
+	options := managerOptions{
+		interval:       monitorDuration * time.Second,
+		safetyInterval: safetyDuration,
+	}
+	for _, opt := range opts {
+		opt(&options)
+	}
+	return options
+}
+
+/*
+ * 下一次轮询之前等待的时间
+ */
+func (o managerOptions) nextInterval() time.Duration {
+	if o.jitter <= 0 {
+		return o.interval
+	}
+	return o.interval + time.Duration(rand.Int63n(int64(o.jitter)))
+}
+
+/*
+ * 文件的修改时间和大小，HashModTime用来跳过没有变化的文件
+ */
+type fileStat struct {
+	modTime int64
+	size    int64
+}
+
+func statFile(path string) (fileStat, error) {
+	fi, err := os.Stat(path)
+	if err != nil {
+		return fileStat{}, err
+	}
+	return fileStat{modTime: fi.ModTime().UnixNano(), size: fi.Size()}, nil
+}
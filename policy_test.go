@@ -0,0 +1,133 @@
+package conf
+
+import (
+	"conf/fileutil"
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestHashAlgorithm(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "hash.json")
+	fileutil.WriteContent(path, `{"key1": 1}`)
+
+	sha1Conf, err := newMConfig(path, configOptions{hash: HashSHA1})
+	if err != nil {
+		t.Fatalf("newMConfig error:%+v", err)
+	}
+	if len(sha1Conf.hash()) != 40 {
+		t.Errorf("hash() = %s; expected a sha1 sum", sha1Conf.hash())
+	}
+	modTimeConf, err := newMConfig(path, configOptions{hash: HashModTime})
+	if err != nil {
+		t.Fatalf("newMConfig error:%+v", err)
+	}
+
+	// 修改时间和大小都不变时HashModTime不会读取文件
+	stat, _ := os.Stat(path)
+	fileutil.WriteContent(path, `{"key1": 2}`)
+	os.Chtimes(path, stat.ModTime(), stat.ModTime())
+	if modTimeConf.checkFileDiff() {
+		t.Errorf("checkFileDiff() = true; expected unchanged mtime and size to be skipped")
+	}
+	if !sha1Conf.checkFileDiff() {
+		t.Errorf("checkFileDiff() = false; expected sha1 to detect the change")
+	}
+
+	// 只修改了时间，内容没有变化
+	fileutil.WriteContent(path, `{"key1": 1}`)
+	os.Chtimes(path, time.Now(), time.Now())
+	if modTimeConf.checkFileDiff() {
+		t.Errorf("checkFileDiff() = true; expected touched file with the same content to be unchanged")
+	}
+	fileutil.WriteContent(path, `{"key1": 3}`)
+	os.Chtimes(path, time.Now().Add(time.Second), time.Now().Add(time.Second))
+	if !modTimeConf.checkFileDiff() {
+		t.Errorf("checkFileDiff() = false; expected changed file to be detected")
+	}
+}
+
+func TestWithWatch(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "nowatch.json")
+	fileutil.WriteContent(path, `{"key1": 1}`)
+	if err := SetConfig("nowatch", path, nil, WithWatch(false)); err != nil {
+		t.Fatalf("SetConfig error:%+v", err)
+	}
+	fileutil.WriteContent(path, `{"key1": 2}`)
+	configManager.checkUpdate()
+	if val, err := MultiConfig("nowatch").GetInt("key1"); err != nil || val != 1 {
+		t.Errorf("GetInt(%s) = %d; expected %d, error:%+v", "key1", val, 1, err)
+	}
+}
+
+func TestManagerOptions(t *testing.T) {
+	opts := newManagerOptions(nil)
+	if opts.nextInterval() != monitorDuration*time.Second {
+		t.Errorf("nextInterval() = %s; expected %s", opts.nextInterval(), monitorDuration*time.Second)
+	}
+	if opts.safetyInterval != safetyDuration {
+		t.Errorf("safetyInterval = %s; expected %s", opts.safetyInterval, safetyDuration)
+	}
+	opts = newManagerOptions([]ManagerOption{WithInterval(200 * time.Millisecond), WithJitter(50 * time.Millisecond)})
+	for i := 0; i < 100; i++ {
+		if d := opts.nextInterval(); d < 200*time.Millisecond || d >= 250*time.Millisecond {
+			t.Fatalf("nextInterval() = %s; expected in [200ms, 250ms)", d)
+		}
+	}
+}
+
+/*
+ * 可以添加监听但是从不发送事件的watcher，模拟NFS、FUSE这类文件系统
+ */
+type silentWatcher struct{}
+
+func (silentWatcher) watch(path string) (bool, error) { return false, nil }
+func (silentWatcher) unwatch(path string) error       { return nil }
+func (silentWatcher) events() <-chan struct{}         { return nil }
+func (silentWatcher) close() error                    { return nil }
+
+func TestSafetyInterval(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "safety.json")
+	fileutil.WriteContent(path, `{"key1": 1}`)
+	m := NewManager()
+	if err := m.SetConfig("safety", path, nil); err != nil {
+		t.Fatalf("SetConfig error:%+v", err)
+	}
+	conf := func() *MConfig { return m.MultiConfig("safety") }
+
+	// 收不到事件时，没有到检查的间隔不会重新加载
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		m.watchLoop(ctx, newManagerOptions([]ManagerOption{WithInterval(20 * time.Millisecond), WithSafetyInterval(time.Hour)}), silentWatcher{})
+	}()
+	time.Sleep(50 * time.Millisecond)
+	fileutil.WriteContent(path, `{"key1": 2}`)
+	time.Sleep(200 * time.Millisecond)
+	if val, err := conf().GetInt("key1"); err != nil || val != 1 {
+		t.Errorf("GetInt(%s) = %d; expected %d before the safety check, error:%+v", "key1", val, 1, err)
+	}
+	cancel()
+	<-done
+
+	// 到了检查的间隔之后重新加载
+	ctx, cancel = context.WithCancel(context.Background())
+	done = make(chan struct{})
+	go func() {
+		defer close(done)
+		m.watchLoop(ctx, newManagerOptions([]ManagerOption{WithInterval(20 * time.Millisecond), WithSafetyInterval(100 * time.Millisecond)}), silentWatcher{})
+	}()
+	if val, err := waitInt(conf, "key1", 2); err != nil || val != 2 {
+		t.Errorf("GetInt(%s) = %d; expected %d after the first check, error:%+v", "key1", val, 2, err)
+	}
+	fileutil.WriteContent(path, `{"key1": 3}`)
+	if val, err := waitInt(conf, "key1", 3); err != nil || val != 3 {
+		t.Errorf("GetInt(%s) = %d; expected %d after the safety check, error:%+v", "key1", val, 3, err)
+	}
+	cancel()
+	<-done
+}
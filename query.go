@@ -0,0 +1,720 @@
+package conf
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+var InvalidQueryErr = errors.New("invalid query")
+
+/*
+ * Query匹配到的一个值，Path是具体的key，可以直接传给Get*方法
+ */
+type QueryResult struct {
+	Path  string
+	Value json.RawMessage
+}
+
+/*
+ * 按照JSONPath查找所有匹配的值，开头的$可以省略，支持以下写法:
+ *   key10.key11[0]、['key10']   对象的key和数组下标，下标为负数时从末尾开始
+ *   *、[*]                      所有的key或者数组元素
+ *   [1:3]、[:2]、[-2:]、[::2]    数组的切片，不包含结束的位置，第三个数字是步长，负数时倒序
+ *   $                           整个配置，结果的Path为空
+ *   ..name、..[0]               递归查找所有层级
+ *   [?(@.enabled==true)]        过滤，支持 == != < <= > >= && || ! 和括号，@表示当前的元素
+ * 例如 Query("key10.key11[*].key12")、Query("servers[?(@.enabled==true)].host")
+ * 结果按照文档的层级排列，对象的key按字典序，没有匹配时返回空的结果
+ * 被命令行参数或者环境变量覆盖的key返回覆盖之后的值
+ */
+func (m *MConfig) Query(expr string) ([]QueryResult, error) {
+	steps, err := compileQuery(expr)
+	if err != nil {
+		return nil, err
+	}
+	results := make([]QueryResult, 0)
+	if m.root == nil {
+		return results, nil
+	}
+	weak := m.untyped()
+	matches := []queryMatch{{node: m.root}}
+	for _, step := range steps {
+		matches = step.apply(matches, weak)
+	}
+	for _, match := range matches {
+		key := match.path.String()
+		value := match.node.raw
+		if match.node == m.root {
+			// 根节点没有原始片段
+			if value, err = json.Marshal(m.rawEntryMap); err != nil {
+				return nil, err
+			}
+		}
+		if weak {
+			value = stripLeaf(value)
+		}
+		if overlayVal, _, ok := m.lookupOverlay(key); ok {
+			value = stringValueNode(overlayVal).raw
+		}
+		results = append(results, QueryResult{Path: key, Value: value})
+	}
+	return results, nil
+}
+
+type queryMatch struct {
+	path Path
+	node *node
+}
+
+func (q queryMatch) child(elem PathElem, n *node) queryMatch {
+	return queryMatch{path: appendPath(q.path, elem), node: n}
+}
+
+/*
+ * 对象按照key的字典序，数组按照下标的顺序返回所有的子节点
+ * weak为true时不返回同时有值和子key的对象中保存值的leafKey
+ */
+func (q queryMatch) children(weak bool) []queryMatch {
+	switch q.node.kind {
+	case objectNode:
+		keys := make([]string, 0, len(q.node.fields))
+		for key := range q.node.fields {
+			if weak && key == leafKey {
+				continue
+			}
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		children := make([]queryMatch, len(keys))
+		for i, key := range keys {
+			children[i] = q.child(PathElem{Key: key}, q.node.fields[key])
+		}
+		return children
+	case arrayNode:
+		children := make([]queryMatch, len(q.node.items))
+		for i, item := range q.node.items {
+			children[i] = q.child(PathElem{Index: i, IsIndex: true}, item)
+		}
+		return children
+	}
+	return nil
+}
+
+/*
+ * 自己以及所有的子孙节点，..使用
+ */
+func (q queryMatch) descendants(out []queryMatch, weak bool) []queryMatch {
+	out = append(out, q)
+	for _, child := range q.children(weak) {
+		out = child.descendants(out, weak)
+	}
+	return out
+}
+
+type selectorKind int
+
+const (
+	selectName selectorKind = iota
+	selectWildcard
+	selectIndex
+	selectSlice
+	selectFilter
+)
+
+/*
+ * 查询中的一步，recursive为true时先展开所有的子孙节点再选择
+ */
+type queryStep struct {
+	recursive bool
+	kind      selectorKind
+	name      string
+	index     int
+	// 切片的范围和步长，没有指定时为nil
+	start, end, step *int
+	filter           *filterExpr
+}
+
+func (s queryStep) apply(matches []queryMatch, weak bool) []queryMatch {
+	if s.recursive {
+		expanded := make([]queryMatch, 0, len(matches))
+		for _, match := range matches {
+			expanded = match.descendants(expanded, weak)
+		}
+		matches = expanded
+	}
+	selected := make([]queryMatch, 0)
+	for _, match := range matches {
+		selected = s.selectFrom(match, weak, selected)
+	}
+	return selected
+}
+
+func (s queryStep) selectFrom(match queryMatch, weak bool, out []queryMatch) []queryMatch {
+	n := match.node
+	switch s.kind {
+	case selectName:
+		if n.kind == objectNode {
+			if child, ok := n.fields[s.name]; ok {
+				out = append(out, match.child(PathElem{Key: s.name}, child))
+			}
+		}
+	case selectWildcard:
+		out = append(out, match.children(weak)...)
+	case selectIndex:
+		if n.kind == arrayNode {
+			index := s.index
+			if index < 0 {
+				index += len(n.items)
+			}
+			if index >= 0 && index < len(n.items) {
+				out = append(out, match.child(PathElem{Index: index, IsIndex: true}, n.items[index]))
+			}
+		}
+	case selectSlice:
+		if n.kind == arrayNode {
+			for _, i := range sliceIndexes(s.start, s.end, s.step, len(n.items)) {
+				out = append(out, match.child(PathElem{Index: i, IsIndex: true}, n.items[i]))
+			}
+		}
+	case selectFilter:
+		for _, child := range match.children(weak) {
+			if s.filter.eval(child.node, weak) {
+				out = append(out, child)
+			}
+		}
+	}
+	return out
+}
+
+/*
+ * 切片的边界，负数从末尾开始计算，超出范围时取最近的合法值
+ */
+/*
+ * 切片选中的下标，规则和python相同: 步长为0时没有结果，步长为负数时从start倒序到end(不包含)
+ */
+func sliceIndexes(start, end, step *int, length int) []int {
+	stepVal := 1
+	if step != nil {
+		stepVal = *step
+	}
+	indexes := make([]int, 0)
+	switch {
+	case stepVal > 0:
+		for i := sliceBound(start, 0, length); i < sliceBound(end, length, length); i += stepVal {
+			indexes = append(indexes, i)
+		}
+	case stepVal < 0:
+		// 倒序时边界的范围是[-1, length-1]，-1表示第一个元素之前
+		upper, lower := length-1, -1
+		if start != nil {
+			upper = reverseBound(*start, length)
+		}
+		if end != nil {
+			lower = reverseBound(*end, length)
+		}
+		for i := upper; i > lower; i += stepVal {
+			indexes = append(indexes, i)
+		}
+	}
+	return indexes
+}
+
+func reverseBound(b int, length int) int {
+	if b < 0 {
+		b += length
+	}
+	if b < -1 {
+		return -1
+	}
+	if b > length-1 {
+		return length - 1
+	}
+	return b
+}
+
+func sliceBound(bound *int, def int, length int) int {
+	if bound == nil {
+		return def
+	}
+	b := *bound
+	if b < 0 {
+		b += length
+	}
+	if b < 0 {
+		return 0
+	}
+	if b > length {
+		return length
+	}
+	return b
+}
+
+/*
+ * 过滤条件，op为空时是操作数: literal不为nil时是字面量，否则是相对于@的path
+ */
+type filterExpr struct {
+	op          string
+	left, right *filterExpr
+	path        Path
+	literal     *node
+}
+
+func (e *filterExpr) operand(current *node) (*node, bool) {
+	if e.literal != nil {
+		return e.literal, true
+	}
+	n, err := current.findPath(e.path)
+	return n, err == nil
+}
+
+func (e *filterExpr) eval(current *node, weak bool) bool {
+	switch e.op {
+	case "":
+		// 单独的@.key表示key存在，单独的字面量按照是否为true判断
+		n, ok := e.operand(current)
+		if e.literal != nil {
+			return n.kind == boolNode && n.boolean
+		}
+		return ok
+	case "!":
+		return !e.left.eval(current, weak)
+	case "&&":
+		return e.left.eval(current, weak) && e.right.eval(current, weak)
+	case "||":
+		return e.left.eval(current, weak) || e.right.eval(current, weak)
+	}
+	left, ok := e.left.operand(current)
+	if !ok {
+		return false
+	}
+	right, ok := e.right.operand(current)
+	if !ok {
+		return false
+	}
+	return compareNodes(e.op, left, right, weak)
+}
+
+/*
+ * 比较两个节点，数字按照数值比较，字符串按照字典序比较，其他类型只能判断是否相等
+ * weak为true时字符串会按照另一边的类型转换，例如ini中的enabled=true可以和true比较
+ */
+func compareNodes(op string, left *node, right *node, weak bool) bool {
+	if weak {
+		left, right = coerceNode(left, right), coerceNode(right, left)
+	}
+	cmp := 0
+	switch {
+	case left.kind == numberNode && right.kind == numberNode:
+		l, _ := strconv.ParseFloat(left.str, 64)
+		r, _ := strconv.ParseFloat(right.str, 64)
+		if l < r {
+			cmp = -1
+		} else if l > r {
+			cmp = 1
+		}
+	case left.kind == stringNode && right.kind == stringNode:
+		cmp = strings.Compare(left.str, right.str)
+	default:
+		equal := left.kind == right.kind
+		if equal && left.kind == boolNode {
+			equal = left.boolean == right.boolean
+		} else if equal && left.kind != nullNode {
+			equal = rawEqual(left.raw, right.raw)
+		}
+		switch op {
+		case "==":
+			return equal
+		case "!=":
+			return !equal
+		}
+		return false
+	}
+	switch op {
+	case "==":
+		return cmp == 0
+	case "!=":
+		return cmp != 0
+	case "<":
+		return cmp < 0
+	case "<=":
+		return cmp <= 0
+	case ">":
+		return cmp > 0
+	case ">=":
+		return cmp >= 0
+	}
+	return false
+}
+
+func coerceNode(n *node, other *node) *node {
+	if n.kind != stringNode {
+		return n
+	}
+	s := strings.TrimSpace(n.str)
+	switch other.kind {
+	case numberNode:
+		if _, err := strconv.ParseFloat(s, 64); err == nil {
+			return &node{kind: numberNode, str: s}
+		}
+	case boolNode:
+		if b, err := strconv.ParseBool(s); err == nil {
+			return &node{kind: boolNode, boolean: b}
+		}
+	}
+	return n
+}
+
+/*
+ * 把查询语句解析成每一步
+ */
+func compileQuery(expr string) ([]queryStep, error) {
+	p := &queryParser{expr: expr}
+	p.skipSpace()
+	// 没有$时第一级可以直接写key
+	first := !p.consume("$")
+	steps := make([]queryStep, 0)
+	for p.skipSpace(); !p.eof(); p.skipSpace() {
+		var step queryStep
+		var err error
+		switch {
+		case p.consume(".."):
+			if p.peek() == '[' {
+				step, err = p.bracket()
+			} else {
+				step, err = p.dotSelector()
+			}
+			step.recursive = true
+		case p.consume("."):
+			step, err = p.dotSelector()
+		case p.peek() == '[':
+			step, err = p.bracket()
+		case first:
+			step, err = p.dotSelector()
+		default:
+			err = p.errorf()
+		}
+		if err != nil {
+			return nil, err
+		}
+		steps = append(steps, step)
+		first = false
+	}
+	// 只有$时返回整个配置
+	if len(steps) == 0 && first {
+		return nil, p.errorf()
+	}
+	return steps, nil
+}
+
+type queryParser struct {
+	expr string
+	pos  int
+}
+
+func (p *queryParser) eof() bool {
+	return p.pos >= len(p.expr)
+}
+
+func (p *queryParser) peek() byte {
+	if p.eof() {
+		return 0
+	}
+	return p.expr[p.pos]
+}
+
+func (p *queryParser) consume(s string) bool {
+	if strings.HasPrefix(p.expr[p.pos:], s) {
+		p.pos += len(s)
+		return true
+	}
+	return false
+}
+
+func (p *queryParser) skipSpace() {
+	for !p.eof() && p.peek() == ' ' {
+		p.pos++
+	}
+}
+
+func (p *queryParser) expect(s string) error {
+	p.skipSpace()
+	if !p.consume(s) {
+		return p.errorf()
+	}
+	return nil
+}
+
+func (p *queryParser) errorf() error {
+	if p.eof() {
+		return fmt.Errorf("%w: unexpected end of %s", InvalidQueryErr, p.expr)
+	}
+	return fmt.Errorf("%w: unexpected %q at %d in %s", InvalidQueryErr, p.expr[p.pos], p.pos, p.expr)
+}
+
+/*
+ * 读取key直到遇到stops中的字符，去掉前后的空格
+ */
+func (p *queryParser) name(stops string) (string, error) {
+	start := p.pos
+	for !p.eof() && strings.IndexByte(stops, p.peek()) < 0 {
+		p.pos++
+	}
+	name := strings.TrimSpace(p.expr[start:p.pos])
+	if name == "" {
+		return "", p.errorf()
+	}
+	return name, nil
+}
+
+/*
+ * .后面的key或者*
+ */
+func (p *queryParser) dotSelector() (queryStep, error) {
+	p.skipSpace()
+	if p.consume("*") {
+		return queryStep{kind: selectWildcard}, nil
+	}
+	name, err := p.name(".[")
+	if err != nil {
+		return queryStep{}, err
+	}
+	return queryStep{kind: selectName, name: name}, nil
+}
+
+/*
+ * [...]中的选择器: *、'key'、下标、切片或者过滤条件
+ */
+func (p *queryParser) bracket() (queryStep, error) {
+	p.consume("[")
+	p.skipSpace()
+	var step queryStep
+	switch c := p.peek(); {
+	case c == '*':
+		p.pos++
+		step = queryStep{kind: selectWildcard}
+	case c == '\'' || c == '"':
+		name, err := p.quoted()
+		if err != nil {
+			return queryStep{}, err
+		}
+		step = queryStep{kind: selectName, name: name}
+	case c == '?':
+		p.pos++
+		filter, err := p.filterOr()
+		if err != nil {
+			return queryStep{}, err
+		}
+		step = queryStep{kind: selectFilter, filter: filter}
+	default:
+		start, err := p.integer()
+		if err != nil {
+			return queryStep{}, err
+		}
+		p.skipSpace()
+		if !p.consume(":") {
+			if start == nil {
+				return queryStep{}, p.errorf()
+			}
+			step = queryStep{kind: selectIndex, index: *start}
+			break
+		}
+		end, err := p.integer()
+		if err != nil {
+			return queryStep{}, err
+		}
+		var stepVal *int
+		p.skipSpace()
+		if p.consume(":") {
+			if stepVal, err = p.integer(); err != nil {
+				return queryStep{}, err
+			}
+		}
+		step = queryStep{kind: selectSlice, start: start, end: end, step: stepVal}
+	}
+	if err := p.expect("]"); err != nil {
+		return queryStep{}, err
+	}
+	return step, nil
+}
+
+/*
+ * 可以省略的整数，切片中省略的边界返回nil
+ */
+func (p *queryParser) integer() (*int, error) {
+	p.skipSpace()
+	start := p.pos
+	if p.peek() == '-' {
+		p.pos++
+	}
+	for !p.eof() && p.peek() >= '0' && p.peek() <= '9' {
+		p.pos++
+	}
+	if p.pos == start {
+		return nil, nil
+	}
+	i, err := strconv.Atoi(p.expr[start:p.pos])
+	if err != nil {
+		p.pos = start
+		return nil, p.errorf()
+	}
+	return &i, nil
+}
+
+/*
+ * 单引号或者双引号括起来的字符串，规则和key中的引号相同
+ */
+func (p *queryParser) quoted() (string, error) {
+	s, end, ok := readQuoted(p.expr, p.pos)
+	if !ok {
+		return "", p.errorf()
+	}
+	p.pos = end
+	return s, nil
+}
+
+func (p *queryParser) filterOr() (*filterExpr, error) {
+	left, err := p.filterAnd()
+	if err != nil {
+		return nil, err
+	}
+	for p.skipSpace(); p.consume("||"); p.skipSpace() {
+		right, err := p.filterAnd()
+		if err != nil {
+			return nil, err
+		}
+		left = &filterExpr{op: "||", left: left, right: right}
+	}
+	return left, nil
+}
+
+func (p *queryParser) filterAnd() (*filterExpr, error) {
+	left, err := p.filterUnary()
+	if err != nil {
+		return nil, err
+	}
+	for p.skipSpace(); p.consume("&&"); p.skipSpace() {
+		right, err := p.filterUnary()
+		if err != nil {
+			return nil, err
+		}
+		left = &filterExpr{op: "&&", left: left, right: right}
+	}
+	return left, nil
+}
+
+func (p *queryParser) filterUnary() (*filterExpr, error) {
+	p.skipSpace()
+	if p.consume("!") {
+		operand, err := p.filterUnary()
+		if err != nil {
+			return nil, err
+		}
+		return &filterExpr{op: "!", left: operand}, nil
+	}
+	if p.consume("(") {
+		expr, err := p.filterOr()
+		if err != nil {
+			return nil, err
+		}
+		if err := p.expect(")"); err != nil {
+			return nil, err
+		}
+		return expr, nil
+	}
+	left, err := p.filterOperand()
+	if err != nil {
+		return nil, err
+	}
+	p.skipSpace()
+	for _, op := range []string{"==", "!=", "<=", ">=", "<", ">"} {
+		if p.consume(op) {
+			right, err := p.filterOperand()
+			if err != nil {
+				return nil, err
+			}
+			return &filterExpr{op: op, left: left, right: right}, nil
+		}
+	}
+	return left, nil
+}
+
+/*
+ * @开头的相对路径，或者数字、字符串、true、false、null字面量
+ */
+func (p *queryParser) filterOperand() (*filterExpr, error) {
+	p.skipSpace()
+	switch c := p.peek(); {
+	case c == '@':
+		p.pos++
+		path, err := p.relativePath()
+		if err != nil {
+			return nil, err
+		}
+		return &filterExpr{path: path}, nil
+	case c == '\'' || c == '"':
+		s, err := p.quoted()
+		if err != nil {
+			return nil, err
+		}
+		return &filterExpr{literal: stringValueNode(s)}, nil
+	}
+	start := p.pos
+	for !p.eof() && strings.IndexByte(" )]&|=!<>", p.peek()) < 0 {
+		p.pos++
+	}
+	text := []byte(p.expr[start:p.pos])
+	if !json.Valid(text) {
+		p.pos = start
+		return nil, p.errorf()
+	}
+	literal, err := parseNode(text)
+	if err != nil || literal.kind == arrayNode || literal.kind == objectNode || literal.kind == stringNode {
+		p.pos = start
+		return nil, p.errorf()
+	}
+	return &filterExpr{literal: literal}, nil
+}
+
+/*
+ * @后面的.key、['key']、[0]
+ */
+func (p *queryParser) relativePath() (Path, error) {
+	path := make(Path, 0)
+	for {
+		switch {
+		case p.consume("."):
+			name, err := p.name(".[ )]&|=!<>")
+			if err != nil {
+				return nil, err
+			}
+			path = append(path, PathElem{Key: name})
+		case p.consume("["):
+			p.skipSpace()
+			if c := p.peek(); c == '\'' || c == '"' {
+				name, err := p.quoted()
+				if err != nil {
+					return nil, err
+				}
+				path = append(path, PathElem{Key: name})
+			} else {
+				index, err := p.integer()
+				if err != nil {
+					return nil, err
+				}
+				if index == nil || *index < 0 {
+					return nil, p.errorf()
+				}
+				path = append(path, PathElem{Index: *index, IsIndex: true})
+			}
+			if err := p.expect("]"); err != nil {
+				return nil, err
+			}
+		default:
+			return path, nil
+		}
+	}
+}
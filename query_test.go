@@ -0,0 +1,83 @@
+package conf
+
+import (
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestQuery(t *testing.T) {
+	m := NewManager()
+	if err := m.SetConfig("query", "testdir/query.json", nil); err != nil {
+		t.Fatalf("SetConfig error:%+v", err)
+	}
+	conf := m.MultiConfig("query")
+
+	cases := map[string][]string{
+		"key10.key11[*].key12":                            {"key10.key11[0].key12", "key10.key11[1].key12"},
+		"$.key10.key11[0].key13":                          {"key10.key11[0].key13"},
+		"$['key10']['key11'][-1]":                         {"key10.key11[2]"},
+		"servers[?(@.enabled==true)].host":                {"servers[0].host", "servers[2].host"},
+		"servers[?(@.enabled == false || @.port > 9000)]": {"servers[1]", "servers[2]"},
+		"servers[?(@.tags && @.tags[1] == 'web')].port":   {"servers[2].port"},
+		"servers[?(!@.tags)].host":                        {"servers[1].host"},
+		"servers[1:3].port":                               {"servers[1].port", "servers[2].port"},
+		"servers[:1].port":                                {"servers[0].port"},
+		"servers[-2:].port":                               {"servers[1].port", "servers[2].port"},
+		"servers[::2].port":                               {"servers[0].port", "servers[2].port"},
+		"servers[::-1].port":                              {"servers[2].port", "servers[1].port", "servers[0].port"},
+		"servers[1::-1].port":                             {"servers[1].port", "servers[0].port"},
+		"servers[-1:0:-2].port":                           {"servers[2].port"},
+		"servers[::0].port":                               {},
+		"db.*.port":                                       {"db.master.port", "db.slave.port"},
+		"..key12":                                         {"key10.key11[0].key12", "key10.key11[1].key12"},
+		"$..tags[0]":                                      {"servers[0].tags[0]", "servers[2].tags[0]"},
+		"db[?(@.port >= 3307)].name":                      {"db.slave.name"},
+		"servers[5].host":                                 {},
+		"notexist.*":                                      {},
+	}
+	for expr, expected := range cases {
+		results, err := conf.Query(expr)
+		paths := make([]string, 0, len(results))
+		for _, r := range results {
+			paths = append(paths, r.Path)
+		}
+		if err != nil || strings.Join(paths, ",") != strings.Join(expected, ",") {
+			t.Errorf("Query(%s) = %v; expected %v, error:%+v", expr, paths, expected, err)
+		}
+	}
+
+	// 结果的Path可以直接传给Get*方法
+	results, _ := conf.Query("servers[?(@.enabled==true)].host")
+	for _, r := range results {
+		host, err := conf.GetString(r.Path)
+		if err != nil || `"`+host+`"` != string(r.Value) {
+			t.Errorf("GetString(%s) = %s; expected %s, error:%+v", r.Path, host, r.Value, err)
+		}
+	}
+
+	// 只有$时返回整个配置
+	results, err := conf.Query("$")
+	var doc map[string]json.RawMessage
+	if err != nil || len(results) != 1 || results[0].Path != "" || json.Unmarshal(results[0].Value, &doc) != nil || len(doc) != 3 || doc["db"] == nil {
+		t.Errorf("Query(%s) = %+v; expected the whole config, error:%+v", "$", results, err)
+	}
+
+	for _, expr := range []string{"", " ", "key10.", "servers[::a]", "servers[", "servers[a]", "servers[?(@.port =)]", "servers[?(@.port == abc)]", "['key10"} {
+		if _, err := conf.Query(expr); !errors.Is(err, InvalidQueryErr) {
+			t.Errorf("Query(%s) error = %+v; expected %+v", expr, err, InvalidQueryErr)
+		}
+	}
+}
+
+func TestQueryWeak(t *testing.T) {
+	m := NewManager()
+	if err := m.SetConfig("ini", "testdir/test.ini", nil); err != nil {
+		t.Fatalf("SetConfig error:%+v", err)
+	}
+	results, err := m.MultiConfig("ini").Query("$[?(@.debug == true && @.port > 3000)].host")
+	if err != nil || len(results) != 1 || results[0].Path != "db.host" {
+		t.Errorf("Query(%s) = %+v; expected db.host, error:%+v", "$[?(@.debug == true && @.port > 3000)].host", results, err)
+	}
+}
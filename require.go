@@ -0,0 +1,217 @@
+package conf
+
+import (
+	"bytes"
+	"fmt"
+	"time"
+)
+
+// 必需的配置项期望的类型
+type ValueType int
+
+const (
+	String ValueType = iota
+	Int
+	Float
+	Bool
+	Time
+	Duration
+	StringSlice
+	IntSlice
+	FloatSlice
+	BoolSlice
+	// 对象，例如db
+	Object
+	Int64
+	Uint64
+	Float64
+	// 保留原始写法的数字，见GetNumber
+	Number
+	Int64Slice
+	Uint64Slice
+	Float64Slice
+	NumberSlice
+	// toml原生的日期时间，见GetDatetime
+	Datetime
+)
+
+func (t ValueType) String() string {
+	switch t {
+	case String:
+		return "string"
+	case Int:
+		return "int"
+	case Float:
+		return "float"
+	case Bool:
+		return "bool"
+	case Time:
+		return "time"
+	case Duration:
+		return "duration"
+	case StringSlice:
+		return "[]string"
+	case IntSlice:
+		return "[]int"
+	case FloatSlice:
+		return "[]float"
+	case BoolSlice:
+		return "[]bool"
+	case Object:
+		return "object"
+	case Int64:
+		return "int64"
+	case Uint64:
+		return "uint64"
+	case Float64:
+		return "float64"
+	case Number:
+		return "number"
+	case Int64Slice:
+		return "[]int64"
+	case Uint64Slice:
+		return "[]uint64"
+	case Float64Slice:
+		return "[]float64"
+	case NumberSlice:
+		return "[]number"
+	case Datetime:
+		return "datetime"
+	}
+	return fmt.Sprintf("type(%d)", int(t))
+}
+
+/*
+ * 按照类型取值，取值的规则和对应的Get*方法相同
+ */
+var typeCheckers = map[ValueType]func(m *MConfig, key string) error{
+	String:       func(m *MConfig, key string) error { _, err := m.GetString(key); return err },
+	Int:          func(m *MConfig, key string) error { _, err := m.GetInt(key); return err },
+	Float:        func(m *MConfig, key string) error { _, err := m.GetFloat(key); return err },
+	Bool:         func(m *MConfig, key string) error { _, err := m.GetBool(key); return err },
+	Time:         func(m *MConfig, key string) error { _, err := m.GetTime(key); return err },
+	StringSlice:  func(m *MConfig, key string) error { _, err := m.GetStringSlice(key); return err },
+	IntSlice:     func(m *MConfig, key string) error { _, err := m.GetIntSlice(key); return err },
+	FloatSlice:   func(m *MConfig, key string) error { _, err := m.GetFloatSlice(key); return err },
+	BoolSlice:    func(m *MConfig, key string) error { _, err := m.GetBoolSlice(key); return err },
+	Int64:        func(m *MConfig, key string) error { _, err := m.GetInt64(key); return err },
+	Uint64:       func(m *MConfig, key string) error { _, err := m.GetUint64(key); return err },
+	Float64:      func(m *MConfig, key string) error { _, err := m.GetFloat64(key); return err },
+	Number:       func(m *MConfig, key string) error { _, err := m.GetNumber(key); return err },
+	Int64Slice:   func(m *MConfig, key string) error { _, err := m.GetInt64Slice(key); return err },
+	Uint64Slice:  func(m *MConfig, key string) error { _, err := m.GetUint64Slice(key); return err },
+	Float64Slice: func(m *MConfig, key string) error { _, err := m.GetFloat64Slice(key); return err },
+	NumberSlice:  func(m *MConfig, key string) error { _, err := m.GetNumberSlice(key); return err },
+	Datetime:     func(m *MConfig, key string) error { _, _, err := m.GetDatetime(key); return err },
+	Duration: func(m *MConfig, key string) error {
+		var d time.Duration
+		return m.Unmarshal(key, &d)
+	},
+	Object: func(m *MConfig, key string) error {
+		raw, err := m.GetRawMessage(key)
+		if err != nil {
+			return err
+		}
+		if rawKind(raw) != '{' {
+			return TypeErr
+		}
+		return nil
+	},
+}
+
+/*
+ * 服务启动时需要的配置项
+ */
+type Requirement struct {
+	Key  string
+	Type ValueType
+}
+
+/*
+ * 声明一个必需的配置项，例如 Require("db.host", String)
+ */
+func Require(key string, typ ValueType) Requirement {
+	return Requirement{Key: key, Type: typ}
+}
+
+/*
+ * 加载配置时检查必需的配置项，不满足时SetConfig返回*RequirementError，重新加载时继续使用原来的配置
+ */
+func WithRequire(reqs ...Requirement) ConfigOption {
+	return func(o *configOptions) {
+		o.required = append(o.required, reqs...)
+	}
+}
+
+/*
+ * 不存在或者类型不对的配置项
+ */
+type RequirementProblem struct {
+	Key  string
+	Type ValueType
+	Err  error
+}
+
+/*
+ * 检查必需的配置项的结果，包含所有不满足的配置项
+ */
+type RequirementError struct {
+	// 配置文件路径
+	Path     string
+	Problems []RequirementProblem
+}
+
+func (e *RequirementError) Error() string {
+	buf := bytes.NewBuffer([]byte{})
+	buf.WriteString(fmt.Sprintf("config file:%s doesn't meet requirements", e.Path))
+	for _, p := range e.Problems {
+		buf.WriteString(fmt.Sprintf("; %s(%s): %s", p.Key, p.Type, p.Err.Error()))
+	}
+	return buf.String()
+}
+
+/*
+ * 可以用errors.Is(err, KeyNotFoundErr)判断是否有缺少的配置项
+ */
+func (e *RequirementError) Unwrap() []error {
+	errs := make([]error, len(e.Problems))
+	for i, p := range e.Problems {
+		errs[i] = p.Err
+	}
+	return errs
+}
+
+/*
+ * 一次检查WithRequire声明的以及传入的所有配置项，返回包含所有问题的*RequirementError
+ */
+func (m *MConfig) Verify(reqs ...Requirement) error {
+	all := append(append([]Requirement{}, m.opts.required...), reqs...)
+	var reqErr *RequirementError
+	for _, req := range all {
+		checker, ok := typeCheckers[req.Type]
+		var err error
+		if !ok {
+			err = TypeErr
+		} else {
+			err = checker(m, req.Key)
+		}
+		if err == nil {
+			continue
+		}
+		if reqErr == nil {
+			reqErr = &RequirementError{Path: m.path}
+		}
+		reqErr.Problems = append(reqErr.Problems, RequirementProblem{Key: req.Key, Type: req.Type, Err: err})
+	}
+	if reqErr == nil {
+		return nil
+	}
+	return reqErr
+}
+
+/*
+ * 检查配置confName，配置不存在时所有的配置项都不满足
+ */
+func Verify(confName string, reqs ...Requirement) error {
+	return MultiConfig(confName).Verify(reqs...)
+}
@@ -0,0 +1,66 @@
+package conf
+
+import (
+	"conf/fileutil"
+	"errors"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestVerify(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "require.json")
+	fileutil.WriteContent(path, `{
+		"db": {"host": "127.0.0.1", "port": "3306", "timeout": "5s"},
+		"servers": ["a", "b"],
+		"created": "2024-01-01T00:00:00Z"
+	}`)
+	m := NewManager()
+	err := m.SetConfig("require", path, nil, WithRequire(
+		Require("db.host", String),
+		Require("db", Object),
+		Require("db.timeout", Duration),
+		Require("servers", StringSlice),
+		Require("created", Time),
+	))
+	if err != nil {
+		t.Fatalf("SetConfig error:%+v", err)
+	}
+
+	err = m.MultiConfig("require").Verify(
+		Require("db.port", Int),
+		Require("db.user", String),
+		Require("servers[5]", String),
+		Require("db.host", Object),
+	)
+	var reqErr *RequirementError
+	if !errors.As(err, &reqErr) {
+		t.Fatalf("Verify error = %+v; expected *RequirementError", err)
+	}
+	keys := make([]string, 0)
+	for _, p := range reqErr.Problems {
+		keys = append(keys, p.Key)
+	}
+	if expected := []string{"db.port", "db.user", "servers[5]", "db.host"}; !reflect.DeepEqual(keys, expected) {
+		t.Errorf("Verify problems = %v; expected %v", keys, expected)
+	}
+	if !errors.Is(err, KeyNotFoundErr) || !errors.Is(err, TypeErr) {
+		t.Errorf("Verify error = %+v; expected to wrap KeyNotFoundErr and TypeErr", err)
+	}
+
+	// 加载时检查声明的配置项
+	err = m.SetConfig("require_missing", path, nil, WithRequire(Require("db.user", String), Require("log.level", String)))
+	if !errors.As(err, &reqErr) || len(reqErr.Problems) != 2 {
+		t.Errorf("SetConfig error = %+v; expected two missing keys", err)
+	}
+	if m.MultiConfig("require_missing") != emptyConfig {
+		t.Errorf("config missing required keys should not be stored")
+	}
+
+	// 重新加载时缺少配置项，继续使用原来的配置
+	fileutil.WriteContent(path, `{"db": {"host": "10.0.0.1"}}`)
+	m.checkUpdate()
+	if val, _ := m.MultiConfig("require").GetString("db.host"); val != "127.0.0.1" {
+		t.Errorf("GetString(%s) = %s; expected the last good value %s", "db.host", val, "127.0.0.1")
+	}
+}
@@ -0,0 +1,150 @@
+package conf
+
+import (
+	"bytes"
+	"conf/fileutil"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/xeipuuv/gojsonschema"
+)
+
+/*
+ * 配置不符合schema时返回的错误，包含每一处违反的约束
+ */
+type SchemaError struct {
+	// 配置文件路径
+	Path       string
+	Violations []SchemaViolation
+}
+
+type SchemaViolation struct {
+	// 出错的key，例如key10.key11[0].key12，根节点为空
+	Key     string
+	Message string
+}
+
+func (e *SchemaError) Error() string {
+	buf := bytes.NewBuffer([]byte{})
+	buf.WriteString(fmt.Sprintf("config file:%s violates schema", e.Path))
+	for _, v := range e.Violations {
+		key := v.Key
+		if key == "" {
+			key = "(root)"
+		}
+		buf.WriteString(fmt.Sprintf("; %s: %s", key, v.Message))
+	}
+	return buf.String()
+}
+
+/*
+ * 使用JSON Schema(draft 4/6/7)校验配置，不符合schema的配置在加载和重新加载时都会被拒绝，
+ * 重新加载失败时继续使用原来的配置
+ */
+func WithSchema(schema string) ConfigOption {
+	return func(o *configOptions) {
+		o.schemaSource = []byte(schema)
+	}
+}
+
+/*
+ * 从文件中读取JSON Schema，规则同WithSchema
+ */
+func WithSchemaFile(path string) ConfigOption {
+	return func(o *configOptions) {
+		o.schemaPath = path
+	}
+}
+
+/*
+ * 编译选项中的schema，没有设置schema时什么都不做
+ */
+func (o *configOptions) compileSchema() error {
+	source := o.schemaSource
+	if o.schemaPath != "" {
+		content, err := fileutil.ReadContent(o.schemaPath)
+		if err != nil {
+			return err
+		}
+		source = content
+	}
+	if source == nil {
+		return nil
+	}
+	schema, err := gojsonschema.NewSchema(gojsonschema.NewBytesLoader(source))
+	if err != nil {
+		return fmt.Errorf("invalid schema: %s", err.Error())
+	}
+	o.schema = schema
+	return nil
+}
+
+/*
+ * 使用schema校验配置，分层配置校验的是合并之后的结果
+ * 校验通过之后再检查WithRequire声明的配置项
+ */
+func (m *MConfig) validate() error {
+	if m.opts.schema == nil {
+		return m.Verify()
+	}
+	content, err := json.Marshal(m.rawEntryMap)
+	if err != nil {
+		return err
+	}
+	var doc interface{}
+	if err := json.Unmarshal(content, &doc); err != nil {
+		return err
+	}
+	result, err := m.opts.schema.Validate(gojsonschema.NewGoLoader(doc))
+	if err != nil {
+		return err
+	}
+	if result.Valid() {
+		return m.Verify()
+	}
+	schemaErr := &SchemaError{Path: m.path}
+	for _, resultErr := range result.Errors() {
+		key := schemaKey(doc, resultErr.Field())
+		// required的错误发生在父节点上，补上缺少的key
+		if resultErr.Type() == "required" {
+			if property, ok := resultErr.Details()["property"].(string); ok {
+				key = joinPath(key, property)
+			}
+		}
+		schemaErr.Violations = append(schemaErr.Violations, SchemaViolation{
+			Key:     key,
+			Message: resultErr.Description(),
+		})
+	}
+	return schemaErr
+}
+
+/*
+ * 把gojsonschema的字段路径(key10.key11.0.key12)转换成travel使用的key(key10.key11[0].key12)
+ * 需要对照文档判断每一级是数组还是对象
+ */
+func schemaKey(doc interface{}, field string) string {
+	if field == "(root)" || field == "" {
+		return ""
+	}
+	key := ""
+	node := doc
+	for _, elem := range strings.Split(field, ".") {
+		switch v := node.(type) {
+		case []interface{}:
+			if index, err := strconv.Atoi(elem); err == nil && index < len(v) {
+				key = indexPath(key, index)
+				node = v[index]
+				continue
+			}
+		case map[string]interface{}:
+			node = v[elem]
+		default:
+			node = nil
+		}
+		key = joinPath(key, elem)
+	}
+	return key
+}
@@ -0,0 +1,59 @@
+package conf
+
+import (
+	"conf/fileutil"
+	"errors"
+	"path/filepath"
+	"testing"
+)
+
+func TestSchemaValidation(t *testing.T) {
+	if err := SetConfig("schema", "testdir/test.json", nil, WithSchemaFile("testdir/test.schema.json")); err != nil {
+		t.Fatalf("SetConfig with valid config error:%+v", err)
+	}
+
+	path := filepath.Join(t.TempDir(), "invalid.json")
+	fileutil.WriteContent(path, `{"key10": {"key11": [{"key12": "value12"}, {"key12": 12}]}}`)
+	err := SetConfig("schema_invalid", path, nil, WithSchemaFile("testdir/test.schema.json"))
+	var schemaErr *SchemaError
+	if !errors.As(err, &schemaErr) {
+		t.Fatalf("SetConfig with invalid config error = %+v; expected SchemaError", err)
+	}
+	keys := map[string]bool{}
+	for _, v := range schemaErr.Violations {
+		keys[v.Key] = true
+	}
+	if !keys["key1"] || !keys["key10.key11[1].key12"] || len(keys) != 2 {
+		t.Errorf("SchemaError violations = %+v; expected key1 and key10.key11[1].key12", schemaErr.Violations)
+	}
+	if MultiConfig("schema_invalid") != emptyConfig {
+		t.Errorf("invalid config should not be stored")
+	}
+
+	if err := SetConfig("schema_bad", "testdir/test.json", nil, WithSchema(`{"type": 1}`)); err == nil {
+		t.Errorf("SetConfig with invalid schema should fail")
+	}
+}
+
+func TestSchemaRejectReload(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "reload.json")
+	fileutil.WriteContent(path, `{"key1": 1, "key10": {}}`)
+	schema := `{"type": "object", "required": ["key1"], "properties": {"key1": {"type": "integer"}}}`
+	if err := SetConfig("schema_reload", path, nil, WithSchema(schema)); err != nil {
+		t.Fatalf("SetConfig error:%+v", err)
+	}
+
+	fileutil.WriteContent(path, `{"key1": "one"}`)
+	configManager.checkUpdate()
+	val, err := MultiConfig("schema_reload").GetInt("key1")
+	if err != nil || val != 1 {
+		t.Errorf("GetInt(%s) = %d; expected last good value %d, error:%+v", "key1", val, 1, err)
+	}
+
+	fileutil.WriteContent(path, `{"key1": 2}`)
+	configManager.checkUpdate()
+	val, err = MultiConfig("schema_reload").GetInt("key1")
+	if err != nil || val != 2 {
+		t.Errorf("GetInt(%s) = %d; expected %d, error:%+v", "key1", val, 2, err)
+	}
+}
@@ -0,0 +1,71 @@
+package conf
+
+import (
+	"time"
+)
+
+/*
+ * 配置的加载状态
+ */
+type ConfigStatus struct {
+	// 最近一次成功加载的时间
+	LastLoad time.Time
+	// 最近一次加载失败的错误以及时间，LastErrorTime晚于LastLoad说明文件当前的内容有问题
+	LastError     error
+	LastErrorTime time.Time
+	// 当前生效的配置对应的文件hash
+	Hash string
+	// 加载失败时文件的版本，文件没有再变化时不重复加载和报错
+	failedVersion string
+}
+
+/*
+ * 配置重新加载失败时的回调，失败的配置不会生效，继续使用原来的配置
+ */
+func WithErrorCallback(callback func(confName string, err error)) ConfigOption {
+	return func(o *configOptions) {
+		o.errorCallback = callback
+	}
+}
+
+/*
+ * 返回配置的加载状态，配置不存在时返回false
+ */
+func Status(confName string) (ConfigStatus, bool) {
+	return configManager.Status(confName)
+}
+
+func (m *MConfigManager) Status(confName string) (ConfigStatus, bool) {
+	if val, ok := m.status.Load(confName); ok {
+		return val.(ConfigStatus), true
+	}
+	return ConfigStatus{}, false
+}
+
+func (m *MConfigManager) loadSucceeded(confName string, conf *MConfig) {
+	status := ConfigStatus{}
+	if val, ok := m.status.Load(confName); ok {
+		status = val.(ConfigStatus)
+	}
+	status.LastLoad = time.Now()
+	status.Hash = conf.hash()
+	status.failedVersion = ""
+	m.status.Store(confName, status)
+}
+
+/*
+ * 记录加载失败，并通知错误回调
+ */
+func (m *MConfigManager) loadFailed(confName string, conf *MConfig, version string, err error) {
+	status := ConfigStatus{}
+	if val, ok := m.status.Load(confName); ok {
+		status = val.(ConfigStatus)
+	}
+	status.LastError = err
+	status.LastErrorTime = time.Now()
+	status.failedVersion = version
+	m.status.Store(confName, status)
+	if conf.opts.errorCallback != nil {
+		conf.opts.errorCallback(confName, err)
+	}
+}
@@ -0,0 +1,60 @@
+package conf
+
+import (
+	"conf/fileutil"
+	"path/filepath"
+	"testing"
+)
+
+func TestKeepLastGoodConfig(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "status.json")
+	fileutil.WriteContent(path, `{"key1": 1}`)
+	var errs []error
+	err := SetConfig("status", path, nil, WithErrorCallback(func(confName string, err error) {
+		if confName != "status" {
+			t.Errorf("error callback name = %s; expected %s", confName, "status")
+		}
+		errs = append(errs, err)
+	}))
+	if err != nil {
+		t.Fatalf("SetConfig error:%+v", err)
+	}
+	status, ok := Status("status")
+	if !ok || status.LastLoad.IsZero() || status.LastError != nil || status.Hash == "" {
+		t.Fatalf("Status = %+v; expected a successful load", status)
+	}
+	goodHash := status.Hash
+
+	// 损坏的json不能清空配置
+	fileutil.WriteContent(path, `{"key1": 2,`)
+	configManager.checkUpdate()
+	val, err := MultiConfig("status").GetInt("key1")
+	if err != nil || val != 1 {
+		t.Errorf("GetInt(%s) = %d; expected last good value %d, error:%+v", "key1", val, 1, err)
+	}
+	status, _ = Status("status")
+	if status.LastError == nil || status.Hash != goodHash || len(errs) != 1 {
+		t.Errorf("Status = %+v, errors = %v; expected one reported error", status, errs)
+	}
+
+	// 文件没有再变化时不重复报错
+	configManager.checkUpdate()
+	if len(errs) != 1 {
+		t.Errorf("errors = %v; expected the broken file reported once", errs)
+	}
+
+	fileutil.WriteContent(path, `{"key1": 3}`)
+	configManager.checkUpdate()
+	val, err = MultiConfig("status").GetInt("key1")
+	if err != nil || val != 3 {
+		t.Errorf("GetInt(%s) = %d; expected %d, error:%+v", "key1", val, 3, err)
+	}
+	status, _ = Status("status")
+	if status.Hash == goodHash || status.LastLoad.Before(status.LastErrorTime) {
+		t.Errorf("Status = %+v; expected a newer successful load", status)
+	}
+
+	if _, ok := Status("not_exist"); ok {
+		t.Errorf("Status of not exist config should return false")
+	}
+}
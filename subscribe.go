@@ -0,0 +1,267 @@
+package conf
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"reflect"
+	"sort"
+	"sync"
+)
+
+// Watch返回的channel的缓冲区大小，满了之后丢弃最旧的事件
+const watchBuffer = 16
+
+/*
+ * pattern中的一级，any为true时elem是*或者[*]
+ * *匹配对象中所有的key以及数组中所有的元素，[*]只匹配数组中的元素
+ */
+type patternElem struct {
+	elem PathElem
+	any  bool
+}
+
+// 配置更新之后收到变化通知的订阅
+type subscription interface {
+	notify(event ChangeEvent)
+}
+
+/*
+ * 订阅配置中匹配pattern的key，只有这个key对应的值发生变化时才调用handler
+ */
+type keySubscription[T any] struct {
+	pattern []patternElem
+	handler func(key string, oldVal T, newVal T)
+}
+
+/*
+ * 订阅配置confName中key的变化，配置重新加载之后key对应的值变化时调用handler
+ * pattern的写法和Get*方法的key相同，另外*匹配任意一级，[*]匹配任意数组下标，
+ * 例如key10.key11[*].key12、servers.*.port、servers["a.b.com"].port、/list/*
+ * 负数下标按照配置中的数组长度计算，例如list[-1]在最后一个元素变化时通知
+ * 每个匹配且变化的key调用一次handler，oldVal、newVal按照Unmarshal的规则解析，key不存在时为零值
+ * 比较的是配置文件中的值，不包含命令行参数和环境变量的覆盖，解析之后相同的值不调用handler
+ * 返回的函数用来取消订阅
+ */
+func OnChange[T any](confName string, pattern string, handler func(key string, oldVal T, newVal T)) (func(), error) {
+	return OnChangeFrom[T](configManager, confName, pattern, handler)
+}
+
+/*
+ * 和OnChange相同，订阅的是管理器m中的配置
+ */
+func OnChangeFrom[T any](m *MConfigManager, confName string, pattern string, handler func(key string, oldVal T, newVal T)) (func(), error) {
+	elems, err := parsePattern(pattern)
+	if err != nil {
+		return nil, err
+	}
+	sub := &keySubscription[T]{pattern: elems, handler: handler}
+	m.subLock.Lock()
+	m.subscriptions[confName] = append(m.subscriptions[confName], sub)
+	m.subLock.Unlock()
+	return func() {
+		m.unsubscribe(confName, sub)
+	}, nil
+}
+
+func (m *MConfigManager) unsubscribe(confName string, sub subscription) {
+	m.subLock.Lock()
+	defer m.subLock.Unlock()
+	subs := m.subscriptions[confName]
+	for i, s := range subs {
+		if s == sub {
+			m.subscriptions[confName] = append(subs[:i:i], subs[i+1:]...)
+			break
+		}
+	}
+	if len(m.subscriptions[confName]) == 0 {
+		delete(m.subscriptions, confName)
+	}
+}
+
+/*
+ * 通过channel接收配置confName的变化，ctx结束后取消订阅并关闭channel
+ * 同一个配置可以有多个Watch，发送不会阻塞monitor，
+ * 消费太慢导致缓冲区满时丢弃最旧的事件，保证最后收到的总是最新的变化
+ */
+func Watch(ctx context.Context, confName string) <-chan ChangeEvent {
+	return configManager.Watch(ctx, confName)
+}
+
+func (m *MConfigManager) Watch(ctx context.Context, confName string) <-chan ChangeEvent {
+	sub := &chanSubscription{ch: make(chan ChangeEvent, watchBuffer)}
+	m.subLock.Lock()
+	m.subscriptions[confName] = append(m.subscriptions[confName], sub)
+	m.subLock.Unlock()
+	go func() {
+		<-ctx.Done()
+		m.unsubscribe(confName, sub)
+		sub.close()
+	}()
+	return sub.ch
+}
+
+/*
+ * Watch的订阅，关闭之后不再发送
+ */
+type chanSubscription struct {
+	lock   sync.Mutex
+	ch     chan ChangeEvent
+	closed bool
+}
+
+func (s *chanSubscription) notify(event ChangeEvent) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if s.closed {
+		return
+	}
+	for {
+		select {
+		case s.ch <- event:
+			return
+		default:
+		}
+		// 缓冲区满了，丢弃最旧的事件
+		select {
+		case <-s.ch:
+		default:
+		}
+	}
+}
+
+func (s *chanSubscription) close() {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.closed = true
+	close(s.ch)
+}
+
+/*
+ * 通知所有订阅了这个配置的订阅者
+ */
+func (m *MConfigManager) notifySubscriptions(event ChangeEvent) {
+	m.subLock.Lock()
+	subs := append([]subscription{}, m.subscriptions[event.Name]...)
+	m.subLock.Unlock()
+	for _, sub := range subs {
+		sub.notify(event)
+	}
+}
+
+func (s *keySubscription[T]) notify(event ChangeEvent) {
+	oldRaws := make(map[string]json.RawMessage, 0)
+	newRaws := make(map[string]json.RawMessage, 0)
+	matchPattern(event.Old.root, s.pattern, nil, event.Old.untyped(), oldRaws)
+	matchPattern(event.New.root, s.pattern, nil, event.New.untyped(), newRaws)
+
+	keys := make([]string, 0, len(newRaws))
+	for key := range oldRaws {
+		keys = append(keys, key)
+	}
+	for key := range newRaws {
+		if _, ok := oldRaws[key]; !ok {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+	oldConf, newConf := event.Old.withoutOverlay(), event.New.withoutOverlay()
+	for _, key := range keys {
+		oldRaw, newRaw := oldRaws[key], newRaws[key]
+		if oldRaw != nil && newRaw != nil && rawEqual(oldRaw, newRaw) {
+			continue
+		}
+		// 不存在的一边保持零值，不处理默认值和必填项
+		var oldVal, newVal T
+		if oldRaw != nil {
+			if err := oldConf.decodeInto(oldRaw, reflect.ValueOf(&oldVal).Elem(), key, oldConf.untyped()); err != nil {
+				log.Printf("decode config:%s key:%s failed, error:%s\n", event.Name, key, err.Error())
+				continue
+			}
+		}
+		if newRaw != nil {
+			if err := newConf.decodeInto(newRaw, reflect.ValueOf(&newVal).Elem(), key, newConf.untyped()); err != nil {
+				log.Printf("decode config:%s key:%s failed, error:%s\n", event.Name, key, err.Error())
+				continue
+			}
+		}
+		if reflect.DeepEqual(oldVal, newVal) {
+			continue
+		}
+		s.handler(key, oldVal, newVal)
+	}
+}
+
+/*
+ * 解析订阅的pattern，使用和Get*方法相同的keyScanner，另外支持*和[*]
+ * 配置的根节点是对象，pattern不能以数组下标开头
+ */
+func parsePattern(pattern string) ([]patternElem, error) {
+	elems := make([]patternElem, 0)
+	scanner := newKeyScanner(pattern)
+	scanner.wildcard = true
+	for {
+		elem, ok := scanner.next()
+		if !ok {
+			break
+		}
+		if len(elems) == 0 && elem.IsIndex {
+			return nil, InvalidKeyErr
+		}
+		elems = append(elems, patternElem{elem: elem, any: scanner.matchAny})
+	}
+	if scanner.err != nil {
+		return nil, scanner.err
+	}
+	return elems, nil
+}
+
+/*
+ * 在节点树中查找所有匹配elems的节点，结果以规范写法的完整key为索引放到out中
+ * weak为true时*不匹配同时有值和子key的对象中保存值的leafKey
+ */
+func matchPattern(n *node, elems []patternElem, path Path, weak bool, out map[string]json.RawMessage) {
+	if n == nil {
+		return
+	}
+	if len(elems) == 0 {
+		out[path.String()] = n.raw
+		return
+	}
+	pe := elems[0]
+	if !pe.any {
+		child, err := n.child(pe.elem)
+		if err != nil {
+			return
+		}
+		elem := pe.elem
+		// JSON Pointer中数组的下标统一写成[i]
+		if index, ok := arrayIndex(elem.Key); ok && n.kind == arrayNode && !elem.IsIndex {
+			elem = PathElem{Index: index, IsIndex: true}
+		}
+		matchPattern(child, elems[1:], appendPath(path, elem), weak, out)
+		return
+	}
+	switch n.kind {
+	case objectNode:
+		if !pe.elem.IsIndex {
+			for key, child := range n.fields {
+				if weak && key == leafKey {
+					continue
+				}
+				matchPattern(child, elems[1:], appendPath(path, PathElem{Key: key}), weak, out)
+			}
+		}
+	case arrayNode:
+		for i, item := range n.items {
+			matchPattern(item, elems[1:], appendPath(path, PathElem{Index: i, IsIndex: true}), weak, out)
+		}
+	}
+}
+
+func appendPath(path Path, elem PathElem) Path {
+	next := make(Path, len(path)+1)
+	copy(next, path)
+	next[len(path)] = elem
+	return next
+}
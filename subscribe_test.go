@@ -0,0 +1,220 @@
+package conf
+
+import (
+	"conf/fileutil"
+	"context"
+	"fmt"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestParsePattern(t *testing.T) {
+	elems, err := parsePattern("key10.key11[*].key12")
+	expected := []patternElem{{PathElem{Key: "key10"}, false}, {PathElem{Key: "key11"}, false}, {PathElem{IsIndex: true}, true}, {PathElem{Key: "key12"}, false}}
+	if err != nil || !reflect.DeepEqual(elems, expected) {
+		t.Errorf("parsePattern = %+v; expected %+v, error:%+v", elems, expected, err)
+	}
+	elems, err = parsePattern(`servers["*"].*`)
+	expected = []patternElem{{PathElem{Key: "servers"}, false}, {PathElem{Key: "*"}, false}, {PathElem{Key: "*"}, true}}
+	if err != nil || !reflect.DeepEqual(elems, expected) {
+		t.Errorf("parsePattern = %+v; expected %+v, error:%+v", elems, expected, err)
+	}
+	for _, pattern := range []string{"", "key10..key12", "key11[a]", "[0]", "matrix[1]x", "/key~2"} {
+		if _, err := parsePattern(pattern); err == nil {
+			t.Errorf("parsePattern(%s) should fail", pattern)
+		}
+	}
+}
+
+/*
+ * pattern和Get*的key使用相同的写法
+ */
+func TestOnChangeKeySyntax(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "subscribe.json")
+	fileutil.WriteContent(path, `{
+		"list": [1, 2, 3],
+		"matrix": [[1, 2, 3], [4, 5, 6]],
+		"servers": {"a.b.com": {"port": 8080}, "c.d.com": {"port": 9090}}
+	}`)
+	m := NewManager()
+	if err := m.SetConfig("syntax", path, nil); err != nil {
+		t.Fatalf("SetConfig error:%+v", err)
+	}
+	var changes []string
+	for _, pattern := range []string{"list[2]", "/list/2", "list[-1]", "matrix[1][2]", "/matrix/*/0", `servers["a.b.com"].port`, "servers.*.port"} {
+		pattern := pattern
+		if _, err := OnChangeFrom(m, "syntax", pattern, func(key string, oldVal int, newVal int) {
+			changes = append(changes, fmt.Sprintf("%s|%s:%d->%d", pattern, key, oldVal, newVal))
+		}); err != nil {
+			t.Fatalf("OnChangeFrom(%s) error:%+v", pattern, err)
+		}
+	}
+
+	fileutil.WriteContent(path, `{
+		"list": [1, 2, 30],
+		"matrix": [[1, 2, 3], [40, 5, 60]],
+		"servers": {"a.b.com": {"port": 8081}, "c.d.com": {"port": 9090}}
+	}`)
+	m.checkUpdate()
+	expected := []string{
+		"list[2]|list[2]:3->30",
+		"/list/2|list[2]:3->30",
+		"list[-1]|list[-1]:3->30",
+		"matrix[1][2]|matrix[1][2]:6->60",
+		"/matrix/*/0|matrix[1][0]:4->40",
+		`servers["a.b.com"].port|servers["a.b.com"].port:8080->8081`,
+		`servers.*.port|servers["a.b.com"].port:8080->8081`,
+	}
+	if !reflect.DeepEqual(changes, expected) {
+		t.Errorf("changes = %v; expected %v", changes, expected)
+	}
+}
+
+/*
+ * 比较文件中的值，不受覆盖的影响，删除的key按照零值通知
+ */
+func TestOnChangeOverlayAndRemoval(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "subscribe.json")
+	fileutil.WriteContent(path, `{"port": 1, "db": {"host": "a"}}`)
+	t.Setenv("SUBAPP_PORT", "42")
+	m := NewManager()
+	if err := m.SetConfig("removal", path, nil, WithEnvOverlay("SUBAPP")); err != nil {
+		t.Fatalf("SetConfig error:%+v", err)
+	}
+	var ports []string
+	OnChangeFrom(m, "removal", "port", func(key string, oldVal int, newVal int) {
+		ports = append(ports, fmt.Sprintf("%d->%d", oldVal, newVal))
+	})
+	type DB struct {
+		Host string `required:"true"`
+	}
+	var dbs []string
+	OnChangeFrom(m, "removal", "db", func(key string, oldVal DB, newVal DB) {
+		dbs = append(dbs, fmt.Sprintf("%q->%q", oldVal.Host, newVal.Host))
+	})
+
+	fileutil.WriteContent(path, `{"port": 2}`)
+	m.checkUpdate()
+	if expected := []string{"1->2"}; !reflect.DeepEqual(ports, expected) {
+		t.Errorf("port changes = %v; expected %v", ports, expected)
+	}
+	if expected := []string{`"a"->""`}; !reflect.DeepEqual(dbs, expected) {
+		t.Errorf("db changes = %v; expected %v", dbs, expected)
+	}
+
+	// 写法不同但是值相同时不通知
+	fileutil.WriteContent(path, `{"port": 2, "db": {"host": "a"}}`)
+	m.checkUpdate()
+	fileutil.WriteContent(path, `{"port": 2, "db": {"host": "\u0061"}}`)
+	m.checkUpdate()
+	if expected := []string{`"a"->""`, `""->"a"`}; !reflect.DeepEqual(dbs, expected) {
+		t.Errorf("db changes = %v; expected %v", dbs, expected)
+	}
+}
+
+func TestOnChange(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "subscribe.json")
+	fileutil.WriteContent(path, `{
+		"db": {"pool": {"size": 10}, "host": "127.0.0.1"},
+		"key10": {"key11": [{"key12": "a"}, {"key12": "b"}]}
+	}`)
+	m := NewManager()
+	if err := m.SetConfig("subscribe", path, nil); err != nil {
+		t.Fatalf("SetConfig error:%+v", err)
+	}
+	var sizes [][2]int
+	cancel, err := OnChangeFrom(m, "subscribe", "db.pool.size", func(key string, oldVal int, newVal int) {
+		sizes = append(sizes, [2]int{oldVal, newVal})
+	})
+	if err != nil {
+		t.Fatalf("OnChangeFrom error:%+v", err)
+	}
+	var items []string
+	OnChangeFrom(m, "subscribe", "key10.key11[*].key12", func(key string, oldVal string, newVal string) {
+		items = append(items, fmt.Sprintf("%s:%s->%s", key, oldVal, newVal))
+	})
+
+	// 订阅的key没有变化时不通知
+	fileutil.WriteContent(path, `{
+		"db": {"pool": {"size": 10}, "host": "10.0.0.1"},
+		"key10": {"key11": [{"key12": "a"}, {"key12": "b"}]}
+	}`)
+	m.checkUpdate()
+	if len(sizes) != 0 || len(items) != 0 {
+		t.Errorf("sizes = %v, items = %v; expected no notification", sizes, items)
+	}
+
+	fileutil.WriteContent(path, `{
+		"db": {"pool": {"size": 20}, "host": "10.0.0.1"},
+		"key10": {"key11": [{"key12": "a"}, {"key12": "c"}, {"key12": "d"}]}
+	}`)
+	m.checkUpdate()
+	if !reflect.DeepEqual(sizes, [][2]int{{10, 20}}) {
+		t.Errorf("sizes = %v; expected [[10 20]]", sizes)
+	}
+	expected := []string{"key10.key11[1].key12:b->c", "key10.key11[2].key12:->d"}
+	if !reflect.DeepEqual(items, expected) {
+		t.Errorf("items = %v; expected %v", items, expected)
+	}
+
+	// 取消订阅之后不再通知
+	cancel()
+	fileutil.WriteContent(path, `{"db": {"pool": {"size": 30}}}`)
+	m.checkUpdate()
+	if len(sizes) != 1 {
+		t.Errorf("sizes = %v; expected no notification after cancel", sizes)
+	}
+}
+
+func TestWatch(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "watch.json")
+	fileutil.WriteContent(path, `{"key1": 0}`)
+	m := NewManager()
+	if err := m.SetConfig("watch", path, nil); err != nil {
+		t.Fatalf("SetConfig error:%+v", err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	fast := m.Watch(ctx, "watch")
+	// 一直不读取的channel不能阻塞其他的订阅者
+	slow := m.Watch(ctx, "watch")
+
+	updates := watchBuffer + 4
+	for i := 1; i <= updates; i++ {
+		fileutil.WriteContent(path, fmt.Sprintf(`{"key1": %d}`, i))
+		m.checkUpdate()
+		select {
+		case event := <-fast:
+			if val, err := event.New.GetInt("key1"); err != nil || val != i {
+				t.Errorf("GetInt(%s) = %d; expected %d, error:%+v", "key1", val, i, err)
+			}
+		case <-time.After(time.Second):
+			t.Fatalf("no event after update %d", i)
+		}
+	}
+
+	// 缓冲区满时丢弃最旧的事件，最后一个总是最新的
+	if len(slow) != watchBuffer {
+		t.Errorf("len(slow) = %d; expected %d", len(slow), watchBuffer)
+	}
+	var last ChangeEvent
+	for i := 0; i < watchBuffer; i++ {
+		last = <-slow
+	}
+	if val, _ := last.New.GetInt("key1"); val != updates {
+		t.Errorf("last event key1 = %d; expected %d", val, updates)
+	}
+
+	cancel()
+	for _, ch := range []<-chan ChangeEvent{fast, slow} {
+		select {
+		case _, ok := <-ch:
+			if ok {
+				t.Errorf("channel should be closed after cancel")
+			}
+		case <-time.After(time.Second):
+			t.Errorf("channel not closed after cancel")
+		}
+	}
+}
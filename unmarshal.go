@@ -0,0 +1,393 @@
+package conf
+
+import (
+	"encoding"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var InvalidTargetErr = errors.New("unmarshal target must be a non-nil pointer")
+var MissingRequiredErr = errors.New("missing required key")
+
+/*
+ * 解析到结构体时出错的字段，Path是字段对应的完整key，例如key10.key11[0].key12
+ */
+type FieldError struct {
+	Path string
+	Err  error
+}
+
+func (e *FieldError) Error() string {
+	return fmt.Sprintf("%s: %s", e.Path, e.Err.Error())
+}
+
+func (e *FieldError) Unwrap() error {
+	return e.Err
+}
+
+var (
+	timeType            = reflect.TypeOf(time.Time{})
+	durationType        = reflect.TypeOf(time.Duration(0))
+	rawMessageType      = reflect.TypeOf(json.RawMessage{})
+	jsonUnmarshalerType = reflect.TypeOf((*json.Unmarshaler)(nil)).Elem()
+	textUnmarshalerType = reflect.TypeOf((*encoding.TextUnmarshaler)(nil)).Elem()
+)
+
+/*
+ * 把key对应的配置解析到out中，out必须是指针
+ * 结构体字段支持以下tag:
+ *   conf:"name"      字段对应的key，不指定时使用字段名(大小写不敏感)，"-"表示忽略
+ *   default:"value"  key不存在时使用的默认值，按照字段类型转换
+ *   required:"true"  key不存在且没有默认值时报错
+ * 命令行参数和环境变量的覆盖同样生效，出错时返回*FieldError，包含出错字段的完整key
+ */
+func (m *MConfig) Unmarshal(key string, out interface{}) error {
+	rv := reflect.ValueOf(out)
+	if rv.Kind() != reflect.Ptr || rv.IsNil() {
+		return InvalidTargetErr
+	}
+	canonical, err := m.resolveKey(key)
+	if err != nil {
+		return &FieldError{Path: key, Err: err}
+	}
+	// 使用节点的原始片段，同时有值和子key的对象在decodeInto中按照目标类型处理
+	val, err := m.travel(canonical, func(n *node, weak bool) (interface{}, error) {
+		return n.raw, nil
+	})
+	if err != nil {
+		return &FieldError{Path: canonical, Err: err}
+	}
+	raw := val.(json.RawMessage)
+	_, _, overlaid := m.lookupOverlay(canonical)
+	return m.decodeInto(raw, rv.Elem(), canonical, m.untyped() || overlaid)
+}
+
+/*
+ * 把整个配置解析到out中，规则和Unmarshal相同
+ */
+func (m *MConfig) UnmarshalAll(out interface{}) error {
+	rv := reflect.ValueOf(out)
+	if rv.Kind() != reflect.Ptr || rv.IsNil() {
+		return InvalidTargetErr
+	}
+	raw, err := json.Marshal(m.rawEntryMap)
+	if err != nil {
+		return err
+	}
+	return m.decodeInto(raw, rv.Elem(), "", m.untyped())
+}
+
+func joinPath(path string, name string) string {
+	if needsQuote(name, path == "") {
+		return path + "[" + quoteKey(name) + "]"
+	}
+	if path == "" {
+		return name
+	}
+	return path + "." + name
+}
+
+func indexPath(path string, index int) string {
+	return path + "[" + strconv.Itoa(index) + "]"
+}
+
+/*
+ * 把raw解析到v中，path是当前值对应的key，raw为nil表示配置中不存在
+ * weak为true时字符串会按照目标类型转换
+ */
+func (m *MConfig) decodeInto(raw json.RawMessage, v reflect.Value, path string, weak bool) error {
+	// 覆盖层的值优先
+	if path != "" {
+		if overlayVal, _, ok := m.lookupOverlay(path); ok {
+			raw, _ = json.Marshal(overlayVal)
+			weak = true
+		}
+	}
+	if raw == nil {
+		// 不存在的结构体仍然需要处理默认值和必填项
+		if v.Kind() == reflect.Struct && v.Type() != timeType {
+			return m.decodeStruct(nil, v, path, weak)
+		}
+		return nil
+	}
+
+	if v.Kind() == reflect.Ptr {
+		if rawKind(raw) == 'n' {
+			v.Set(reflect.Zero(v.Type()))
+			return nil
+		}
+		if v.IsNil() {
+			v.Set(reflect.New(v.Type().Elem()))
+		}
+		return m.decodeInto(raw, v.Elem(), path, weak)
+	}
+
+	// 没有类型的配置中同时有值和子key的对象，解析成对象时忽略leafKey，解析成值时使用leafKey中保存的值
+	if weak && rawKind(raw) == '{' {
+		switch {
+		case v.Kind() == reflect.Map || (v.Kind() == reflect.Struct && v.Type() != timeType):
+		case v.Kind() == reflect.Interface || v.Type() == rawMessageType:
+			raw = stripLeaf(raw)
+		default:
+			if leaf, ok := leafRaw(raw); ok {
+				raw = leaf
+			}
+		}
+	}
+
+	switch v.Type() {
+	case timeType:
+		var strVal string
+		if err := json.Unmarshal(raw, &strVal); err != nil {
+			return &FieldError{Path: path, Err: err}
+		}
+		t, err := parseTime(strVal)
+		if err != nil {
+			return &FieldError{Path: path, Err: err}
+		}
+		v.Set(reflect.ValueOf(t))
+		return nil
+	case durationType:
+		var strVal string
+		if err := json.Unmarshal(raw, &strVal); err == nil {
+			d, err := time.ParseDuration(strings.TrimSpace(strVal))
+			if err != nil {
+				return &FieldError{Path: path, Err: err}
+			}
+			v.SetInt(int64(d))
+			return nil
+		}
+	case rawMessageType:
+		v.SetBytes(append([]byte{}, raw...))
+		return nil
+	}
+
+	// 自定义了解析方法的类型交给类型自己处理
+	if v.CanAddr() {
+		if v.Addr().Type().Implements(jsonUnmarshalerType) {
+			if err := json.Unmarshal(raw, v.Addr().Interface()); err != nil {
+				return &FieldError{Path: path, Err: err}
+			}
+			return nil
+		}
+		if v.Addr().Type().Implements(textUnmarshalerType) {
+			var strVal string
+			if err := json.Unmarshal(raw, &strVal); err != nil {
+				return &FieldError{Path: path, Err: err}
+			}
+			if err := v.Addr().Interface().(encoding.TextUnmarshaler).UnmarshalText([]byte(strVal)); err != nil {
+				return &FieldError{Path: path, Err: err}
+			}
+			return nil
+		}
+	}
+
+	switch v.Kind() {
+	case reflect.Struct:
+		return m.decodeStruct(raw, v, path, weak)
+	case reflect.Map:
+		return m.decodeMap(raw, v, path, weak)
+	case reflect.Slice, reflect.Array:
+		return m.decodeSlice(raw, v, path, weak)
+	}
+	return decodeScalar(raw, v, path, weak)
+}
+
+func (m *MConfig) decodeStruct(raw json.RawMessage, v reflect.Value, path string, weak bool) error {
+	rawMap := make(map[string]json.RawMessage, 0)
+	if raw != nil {
+		if err := json.Unmarshal(raw, &rawMap); err != nil {
+			return &FieldError{Path: path, Err: err}
+		}
+	}
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if field.PkgPath != "" && !field.Anonymous {
+			continue
+		}
+		name, ok := field.Tag.Lookup("conf")
+		if name == "-" {
+			continue
+		}
+		// 没有tag的嵌入结构体，字段平铺在当前对象中
+		if field.Anonymous && !ok {
+			fieldType := field.Type
+			if fieldType.Kind() == reflect.Ptr {
+				fieldType = fieldType.Elem()
+			}
+			if fieldType.Kind() == reflect.Struct {
+				fv := v.Field(i)
+				if fv.Kind() == reflect.Ptr {
+					if !fv.CanSet() {
+						continue
+					}
+					if fv.IsNil() {
+						fv.Set(reflect.New(fieldType))
+					}
+					fv = fv.Elem()
+				}
+				if err := m.decodeStruct(raw, fv, path, weak); err != nil {
+					return err
+				}
+				continue
+			}
+		}
+		if field.PkgPath != "" {
+			continue
+		}
+		if name == "" {
+			name = field.Name
+		}
+		fieldPath := joinPath(path, name)
+		fieldRaw, ok := lookupField(rawMap, name)
+		fieldWeak := weak
+		if !ok {
+			if _, _, overlaid := m.lookupOverlay(fieldPath); !overlaid {
+				if def, hasDefault := field.Tag.Lookup("default"); hasDefault {
+					fieldRaw, _ = json.Marshal(def)
+					fieldWeak = true
+				} else if field.Tag.Get("required") == "true" {
+					return &FieldError{Path: fieldPath, Err: MissingRequiredErr}
+				}
+			}
+		}
+		if err := m.decodeInto(fieldRaw, v.Field(i), fieldPath, fieldWeak); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+/*
+ * 先按照名字精确查找，找不到时大小写不敏感查找
+ */
+func lookupField(rawMap map[string]json.RawMessage, name string) (json.RawMessage, bool) {
+	if raw, ok := rawMap[name]; ok {
+		return raw, true
+	}
+	for key, raw := range rawMap {
+		if strings.EqualFold(key, name) {
+			return raw, true
+		}
+	}
+	return nil, false
+}
+
+func (m *MConfig) decodeMap(raw json.RawMessage, v reflect.Value, path string, weak bool) error {
+	if v.Type().Key().Kind() != reflect.String {
+		return &FieldError{Path: path, Err: TypeErr}
+	}
+	if rawKind(raw) == 'n' {
+		v.Set(reflect.Zero(v.Type()))
+		return nil
+	}
+	var rawMap map[string]json.RawMessage
+	if err := json.Unmarshal(raw, &rawMap); err != nil {
+		return &FieldError{Path: path, Err: err}
+	}
+	if v.IsNil() {
+		v.Set(reflect.MakeMapWithSize(v.Type(), len(rawMap)))
+	}
+	for key, itemRaw := range rawMap {
+		if weak && key == leafKey {
+			continue
+		}
+		item := reflect.New(v.Type().Elem()).Elem()
+		if err := m.decodeInto(itemRaw, item, joinPath(path, key), weak); err != nil {
+			return err
+		}
+		v.SetMapIndex(reflect.ValueOf(key).Convert(v.Type().Key()), item)
+	}
+	return nil
+}
+
+func (m *MConfig) decodeSlice(raw json.RawMessage, v reflect.Value, path string, weak bool) error {
+	if v.Kind() == reflect.Slice && rawKind(raw) == 'n' {
+		v.Set(reflect.Zero(v.Type()))
+		return nil
+	}
+	var rawSliceVal []json.RawMessage
+	if err := json.Unmarshal(raw, &rawSliceVal); err != nil {
+		// 没有类型的配置中数组可以写成逗号分隔的字符串
+		var strVal string
+		if !weak || json.Unmarshal(raw, &strVal) != nil {
+			return &FieldError{Path: path, Err: err}
+		}
+		rawSliceVal = splitWeakSlice(strVal)
+	}
+	if v.Kind() == reflect.Array {
+		if len(rawSliceVal) > v.Len() {
+			return &FieldError{Path: path, Err: InvalidSliceIndexErr}
+		}
+	} else {
+		v.Set(reflect.MakeSlice(v.Type(), len(rawSliceVal), len(rawSliceVal)))
+	}
+	for i, itemRaw := range rawSliceVal {
+		if err := m.decodeInto(itemRaw, v.Index(i), indexPath(path, i), weak); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func decodeScalar(raw json.RawMessage, v reflect.Value, path string, weak bool) error {
+	if !v.CanAddr() {
+		return &FieldError{Path: path, Err: TypeErr}
+	}
+	err := json.Unmarshal(raw, v.Addr().Interface())
+	if err == nil {
+		return nil
+	}
+	if weak {
+		var strVal string
+		if json.Unmarshal(raw, &strVal) == nil {
+			if weakErr := setWeakScalar(strings.TrimSpace(strVal), v); weakErr == nil {
+				return nil
+			} else {
+				err = weakErr
+			}
+		}
+	}
+	return &FieldError{Path: path, Err: err}
+}
+
+/*
+ * 按照v的类型转换字符串
+ */
+func setWeakScalar(strVal string, v reflect.Value) error {
+	switch v.Kind() {
+	case reflect.Bool:
+		boolVal, err := strconv.ParseBool(strVal)
+		if err != nil {
+			return err
+		}
+		v.SetBool(boolVal)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		intVal, err := strconv.ParseInt(strVal, 10, v.Type().Bits())
+		if err != nil {
+			return err
+		}
+		v.SetInt(intVal)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		uintVal, err := strconv.ParseUint(strVal, 10, v.Type().Bits())
+		if err != nil {
+			return err
+		}
+		v.SetUint(uintVal)
+	case reflect.Float32, reflect.Float64:
+		floatVal, err := strconv.ParseFloat(strVal, v.Type().Bits())
+		if err != nil {
+			return err
+		}
+		v.SetFloat(floatVal)
+	default:
+		return TypeErr
+	}
+	return nil
+}
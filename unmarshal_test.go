@@ -0,0 +1,127 @@
+package conf
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+type testItem struct {
+	Key12 string `conf:"key12"`
+	Key13 string `conf:"key13" default:"default13"`
+}
+
+type testKey10 struct {
+	Items []testItem `conf:"key11"`
+}
+
+type testDocument struct {
+	Key1    int               `conf:"key1"`
+	Key2    string            // 没有tag时按字段名大小写不敏感匹配
+	Key3    *bool             `conf:"key3"`
+	Key4    float64           `conf:"key4"`
+	Key5    map[string]string `conf:"key5"`
+	Key7    []string          `conf:"key7"`
+	Key10   testKey10         `conf:"key10"`
+	Port    int               `conf:"port" default:"8080"`
+	Timeout time.Duration     `conf:"timeout" default:"1.5s"`
+	Ignored string            `conf:"-"`
+}
+
+func TestUnmarshal(t *testing.T) {
+	SetConfig("unmarshal", "testdir/test.json", nil)
+	conf := MultiConfig("unmarshal")
+
+	var key10 testKey10
+	if err := conf.Unmarshal("key10", &key10); err != nil {
+		t.Fatalf("Unmarshal(%s) error:%+v", "key10", err)
+	}
+	if len(key10.Items) != 2 || key10.Items[0].Key12 != "value12" || key10.Items[1].Key12 != "" {
+		t.Errorf("Unmarshal(%s) = %+v; unexpected items", "key10", key10)
+	}
+	if key10.Items[0].Key13 != "value13" || key10.Items[1].Key13 != "default13" {
+		t.Errorf("Unmarshal(%s) = %+v; default tag not applied", "key10", key10)
+	}
+
+	var item testItem
+	if err := conf.Unmarshal("key10 . key11[ 0 ]", &item); err != nil || item.Key12 != "value12" {
+		t.Errorf("Unmarshal(%s) = %+v, error:%+v", "key10.key11[0]", item, err)
+	}
+
+	var key9 []int
+	if err := conf.Unmarshal("key8.key9", &key9); err != nil || len(key9) != 3 || key9[2] != 3 {
+		t.Errorf("Unmarshal(%s) = %v, error:%+v", "key8.key9", key9, err)
+	}
+
+	if err := conf.Unmarshal("key1", item); err != InvalidTargetErr {
+		t.Errorf("Unmarshal to non pointer error = %+v; expected %+v", err, InvalidTargetErr)
+	}
+}
+
+func TestUnmarshalAll(t *testing.T) {
+	SetConfig("unmarshal", "testdir/test.json", nil)
+	conf := MultiConfig("unmarshal")
+
+	var doc testDocument
+	if err := conf.UnmarshalAll(&doc); err != nil {
+		t.Fatalf("UnmarshalAll error:%+v", err)
+	}
+	if doc.Key1 != 1 || doc.Key2 != "value2" || doc.Key3 == nil || *doc.Key3 != true || doc.Key4 != 0.1 {
+		t.Errorf("UnmarshalAll = %+v; unexpected scalar values", doc)
+	}
+	if doc.Key5["key6"] != "value6" || len(doc.Key7) != 3 || doc.Key7[0] != "key7_1" {
+		t.Errorf("UnmarshalAll = %+v; unexpected map or slice values", doc)
+	}
+	if doc.Key10.Items[1].Key12 != "" || doc.Key10.Items[0].Key12 != "value12" {
+		t.Errorf("UnmarshalAll = %+v; unexpected nested values", doc)
+	}
+	if doc.Port != 8080 || doc.Timeout != 1500*time.Millisecond {
+		t.Errorf("UnmarshalAll = %+v; default tag not applied", doc)
+	}
+}
+
+func TestUnmarshalErrors(t *testing.T) {
+	SetConfig("unmarshal", "testdir/test.json", nil)
+	conf := MultiConfig("unmarshal")
+
+	var required struct {
+		Items []struct {
+			Key14 string `conf:"key14" required:"true"`
+		} `conf:"key11"`
+	}
+	err := conf.Unmarshal("key10", &required)
+	var fieldErr *FieldError
+	if !errors.As(err, &fieldErr) || fieldErr.Path != "key10.key11[0].key14" || !errors.Is(err, MissingRequiredErr) {
+		t.Errorf("Unmarshal required error = %+v; expected missing %s", err, "key10.key11[0].key14")
+	}
+
+	var mistyped struct {
+		Key12 int `conf:"key12"`
+	}
+	err = conf.Unmarshal("key10.key11[0]", &mistyped)
+	if !errors.As(err, &fieldErr) || fieldErr.Path != "key10.key11[0].key12" {
+		t.Errorf("Unmarshal mistyped error = %+v; expected path %s", err, "key10.key11[0].key12")
+	}
+
+	err = conf.Unmarshal("key10.not", &mistyped)
+	if !errors.Is(err, KeyNotFoundErr) {
+		t.Errorf("Unmarshal not exist key error = %+v; expected %+v", err, KeyNotFoundErr)
+	}
+}
+
+func TestUnmarshalWeak(t *testing.T) {
+	SetConfig("ini", "testdir/test.ini", nil)
+	var db struct {
+		Host    string   `conf:"host"`
+		Port    uint16   `conf:"port"`
+		Timeout float64  `conf:"timeout"`
+		Debug   bool     `conf:"debug"`
+		Tags    []string `conf:"tags"`
+	}
+	if err := MultiConfig("ini").Unmarshal("db", &db); err != nil {
+		t.Fatalf("Unmarshal(%s) error:%+v", "db", err)
+	}
+	if db.Host != "127.0.0.1" || db.Port != 3306 || db.Timeout != 1.5 || !db.Debug || len(db.Tags) != 3 {
+		t.Errorf("Unmarshal(%s) = %+v; unexpected values", "db", db)
+	}
+}
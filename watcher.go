@@ -0,0 +1,135 @@
+package conf
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// 解析符号链接时最多经过的链接数，和linux的MAXSYMLINKS一致
+const maxSymlinkHops = 40
+
+// 文件连续变化时，等待这么久没有新的变化才通知，避免一次保存触发多次重新加载
+const debounceDuration = 100 * time.Millisecond
+
+/*
+ * 基于事件的文件监听，监听的是文件所在的目录，这样先写临时文件再rename覆盖的修改方式也能感知到
+ * 不支持的平台或者文件系统上创建失败，monitor退回到定时轮询
+ */
+type fileWatcher interface {
+	// 监听path的变化，返回是否是新加入的监听，同一个文件重复调用没有影响
+	watch(path string) (bool, error)
+	// 取消监听path，没有监听的文件直接忽略
+	unwatch(path string) error
+	// 有文件发生变化时收到通知，多次变化会合并成一次
+	events() <-chan struct{}
+	close() error
+}
+
+/*
+ * 配置对应的所有文件，分层配置为每一层的文件
+ */
+func (m *MConfig) files() []string {
+	if len(m.layers) > 0 {
+		paths := make([]string, len(m.layers))
+		for i, layer := range m.layers {
+			paths[i] = layer.path
+		}
+		return paths
+	}
+	if m.path == "" {
+		return nil
+	}
+	return []string{m.path}
+}
+
+/*
+ * 解析path时经过的所有符号链接，包括路径中间作为目录的链接，例如ConfigMap挂载的目录中
+ *   config.json -> ..data/config.json
+ *   ..data -> ..2024_01_01_00_00_00.000000000
+ * 返回config.json和..data两个链接，ConfigMap更新时替换的是..data，需要一起监听
+ */
+func symlinkChain(path string) ([]string, error) {
+	path, err := filepath.Abs(path)
+	if err != nil {
+		return nil, err
+	}
+	links := make([]string, 0)
+	hops := 0
+	if _, err := collectSymlinks(path, &links, &hops); err != nil {
+		return nil, err
+	}
+	return links, nil
+}
+
+/*
+ * 逐级解析path，把遇到的符号链接记录到links中，返回解析后的路径
+ */
+func collectSymlinks(path string, links *[]string, hops *int) (string, error) {
+	dir, base := filepath.Dir(path), filepath.Base(path)
+	if dir == path {
+		return path, nil
+	}
+	realDir, err := collectSymlinks(dir, links, hops)
+	if err != nil {
+		return "", err
+	}
+	p := filepath.Join(realDir, base)
+	fi, err := os.Lstat(p)
+	if err != nil {
+		return "", err
+	}
+	if fi.Mode()&os.ModeSymlink == 0 {
+		return p, nil
+	}
+	*hops++
+	if *hops > maxSymlinkHops {
+		return "", errors.New("too many levels of symbolic links: " + path)
+	}
+	*links = append(*links, p)
+	dest, err := os.Readlink(p)
+	if err != nil {
+		return "", err
+	}
+	if !filepath.IsAbs(dest) {
+		dest = filepath.Join(realDir, dest)
+	}
+	return collectSymlinks(dest, links, hops)
+}
+
+/*
+ * 监听配置文件，同时监听路径上的每个符号链接以及最终指向的文件
+ * 任意一个链接被替换或者最终的文件被修改都会收到通知，监听的路径记录到watched中
+ */
+func watchFile(watcher fileWatcher, path string, watched map[string]bool) (bool, error) {
+	added, err := watcher.watch(path)
+	if err != nil {
+		return false, err
+	}
+	if watched != nil {
+		watched[path] = true
+	}
+	links, err := symlinkChain(path)
+	if err != nil {
+		return added, err
+	}
+	if len(links) == 0 {
+		return added, nil
+	}
+	target, err := filepath.EvalSymlinks(path)
+	if err != nil {
+		return added, err
+	}
+	for _, p := range append(links, target) {
+		linkAdded, err := watcher.watch(p)
+		if err != nil {
+			return added, err
+		}
+		if watched != nil {
+			watched[p] = true
+		}
+		added = added || linkAdded
+	}
+	return added, nil
+}
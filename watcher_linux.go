@@ -0,0 +1,171 @@
+//go:build linux
+
+package conf
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"sync"
+	"syscall"
+	"time"
+	"unsafe"
+)
+
+// 需要关注的事件，覆盖了直接写入、rename覆盖、删除后重建以及修改属性
+const inotifyMask = syscall.IN_CLOSE_WRITE | syscall.IN_MODIFY | syscall.IN_MOVED_TO | syscall.IN_MOVED_FROM |
+	syscall.IN_CREATE | syscall.IN_DELETE | syscall.IN_ATTRIB
+
+type inotifyWatcher struct {
+	file *os.File
+	fd   int
+	lock sync.Mutex
+	// 目录对应的watch descriptor
+	dirs map[string]int
+	// 每个watch descriptor下需要关注的文件名
+	names   map[int]map[string]bool
+	changed chan struct{}
+	timer   *time.Timer
+}
+
+func newFileWatcher() (fileWatcher, error) {
+	fd, err := syscall.InotifyInit1(syscall.IN_CLOEXEC | syscall.IN_NONBLOCK)
+	if err != nil {
+		return nil, err
+	}
+	// 非阻塞的fd交给runtime的poller，close的时候阻塞中的Read会立即返回
+	w := &inotifyWatcher{
+		file:    os.NewFile(uintptr(fd), "inotify"),
+		fd:      fd,
+		dirs:    make(map[string]int, 0),
+		names:   make(map[int]map[string]bool, 0),
+		changed: make(chan struct{}, 1),
+	}
+	go w.readEvents()
+	return w, nil
+}
+
+func (w *inotifyWatcher) watch(path string) (bool, error) {
+	path, err := filepath.Abs(path)
+	if err != nil {
+		return false, err
+	}
+	dir, name := filepath.Dir(path), filepath.Base(path)
+	w.lock.Lock()
+	defer w.lock.Unlock()
+	wd, ok := w.dirs[dir]
+	if !ok {
+		wd, err = syscall.InotifyAddWatch(w.fd, dir, inotifyMask)
+		if err != nil {
+			return false, &os.PathError{Op: "inotify_add_watch", Path: dir, Err: err}
+		}
+		w.dirs[dir] = wd
+	}
+	if w.names[wd] == nil {
+		w.names[wd] = make(map[string]bool, 0)
+	}
+	if w.names[wd][name] {
+		return false, nil
+	}
+	w.names[wd][name] = true
+	return true, nil
+}
+
+func (w *inotifyWatcher) unwatch(path string) error {
+	path, err := filepath.Abs(path)
+	if err != nil {
+		return err
+	}
+	dir, name := filepath.Dir(path), filepath.Base(path)
+	w.lock.Lock()
+	defer w.lock.Unlock()
+	wd, ok := w.dirs[dir]
+	if !ok {
+		return nil
+	}
+	delete(w.names[wd], name)
+	// 目录下没有需要关注的文件了，移除整个目录的监听
+	if len(w.names[wd]) == 0 {
+		delete(w.dirs, dir)
+		delete(w.names, wd)
+		if _, err := syscall.InotifyRmWatch(w.fd, uint32(wd)); err != nil {
+			return &os.PathError{Op: "inotify_rm_watch", Path: dir, Err: err}
+		}
+	}
+	return nil
+}
+
+func (w *inotifyWatcher) events() <-chan struct{} {
+	return w.changed
+}
+
+func (w *inotifyWatcher) close() error {
+	return w.file.Close()
+}
+
+func (w *inotifyWatcher) readEvents() {
+	buf := make([]byte, syscall.SizeofInotifyEvent*4096)
+	for {
+		n, err := w.file.Read(buf)
+		if err != nil {
+			return
+		}
+		offset := 0
+		for offset+syscall.SizeofInotifyEvent <= n {
+			event := (*syscall.InotifyEvent)(unsafe.Pointer(&buf[offset]))
+			nameBytes := buf[offset+syscall.SizeofInotifyEvent : offset+syscall.SizeofInotifyEvent+int(event.Len)]
+			offset += syscall.SizeofInotifyEvent + int(event.Len)
+			if w.relevant(event, string(bytes.TrimRight(nameBytes, "\x00"))) {
+				w.trigger()
+			}
+		}
+	}
+}
+
+/*
+ * 判断事件是否和监听的文件有关
+ */
+func (w *inotifyWatcher) relevant(event *syscall.InotifyEvent, name string) bool {
+	// 事件队列溢出，可能丢了事件，直接触发一次检查
+	if event.Mask&syscall.IN_Q_OVERFLOW != 0 {
+		return true
+	}
+	w.lock.Lock()
+	defer w.lock.Unlock()
+	wd := int(event.Wd)
+	// 目录被删除或者卸载，之后需要重新添加
+	if event.Mask&syscall.IN_IGNORED != 0 {
+		// unwatch主动移除的监听
+		if _, ok := w.names[wd]; !ok {
+			return false
+		}
+		for dir, dirWd := range w.dirs {
+			if dirWd == wd {
+				delete(w.dirs, dir)
+			}
+		}
+		delete(w.names, wd)
+		return true
+	}
+	return w.names[wd][name]
+}
+
+/*
+ * 合并连续的事件，debounceDuration内没有新的事件才通知
+ */
+func (w *inotifyWatcher) trigger() {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+	if w.timer == nil {
+		w.timer = time.AfterFunc(debounceDuration, w.notify)
+		return
+	}
+	w.timer.Reset(debounceDuration)
+}
+
+func (w *inotifyWatcher) notify() {
+	select {
+	case w.changed <- struct{}{}:
+	default:
+	}
+}
@@ -0,0 +1,158 @@
+//go:build linux
+
+package conf
+
+import (
+	"conf/fileutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func waitEvent(watcher fileWatcher) bool {
+	select {
+	case <-watcher.events():
+		return true
+	case <-time.After(2 * time.Second):
+		return false
+	}
+}
+
+func TestInotifyWatcher(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "watch.json")
+	fileutil.WriteContent(path, `{"key1": 1}`)
+
+	watcher, err := newFileWatcher()
+	if err != nil {
+		t.Fatalf("newFileWatcher error:%+v", err)
+	}
+	defer watcher.close()
+	if added, err := watcher.watch(path); err != nil || !added {
+		t.Fatalf("watch = %t; expected newly added, error:%+v", added, err)
+	}
+	if added, _ := watcher.watch(path); added {
+		t.Errorf("watch the same file twice should not add it again")
+	}
+
+	// 连续多次写入只通知一次
+	for i := 0; i < 5; i++ {
+		fileutil.WriteContent(path, `{"key1": 2}`)
+	}
+	if !waitEvent(watcher) {
+		t.Fatalf("no event after writing the file")
+	}
+	select {
+	case <-watcher.events():
+		t.Errorf("burst of writes should be debounced into one event")
+	case <-time.After(3 * debounceDuration):
+	}
+
+	// 先写临时文件再rename覆盖
+	tmp := filepath.Join(dir, "watch.json.tmp")
+	fileutil.WriteContent(tmp, `{"key1": 3}`)
+	if err := os.Rename(tmp, path); err != nil {
+		t.Fatalf("rename error:%+v", err)
+	}
+	if !waitEvent(watcher) {
+		t.Fatalf("no event after renaming over the file")
+	}
+
+	// 同目录下其他文件的变化不通知
+	fileutil.WriteContent(filepath.Join(dir, "other.json"), `{}`)
+	select {
+	case <-watcher.events():
+		t.Errorf("unrelated file should not trigger an event")
+	case <-time.After(3 * debounceDuration):
+	}
+
+	// 取消监听之后不再通知
+	if err := watcher.unwatch(path); err != nil {
+		t.Fatalf("unwatch error:%+v", err)
+	}
+	fileutil.WriteContent(path, `{"key1": 4}`)
+	select {
+	case <-watcher.events():
+		t.Errorf("unwatched file should not trigger an event")
+	case <-time.After(3 * debounceDuration):
+	}
+	if added, err := watcher.watch(path); err != nil || !added {
+		t.Errorf("watch after unwatch = %t; expected newly added, error:%+v", added, err)
+	}
+}
+
+/*
+ * 模拟ConfigMap挂载的目录结构
+ *   config.json -> ..data/config.json
+ *   ..data -> ts1
+ * 更新时创建新的目录，再把..data原子替换成指向新目录的链接
+ */
+func swapConfigMap(t *testing.T, dir string, ts string, content string) {
+	if err := os.Mkdir(filepath.Join(dir, ts), 0755); err != nil {
+		t.Fatalf("mkdir error:%+v", err)
+	}
+	fileutil.WriteContent(filepath.Join(dir, ts, "config.json"), content)
+	tmp := filepath.Join(dir, "..data_tmp")
+	if err := os.Symlink(ts, tmp); err != nil {
+		t.Fatalf("symlink error:%+v", err)
+	}
+	if err := os.Rename(tmp, filepath.Join(dir, "..data")); err != nil {
+		t.Fatalf("rename error:%+v", err)
+	}
+}
+
+func TestConfigMapSymlinkSwap(t *testing.T) {
+	dir := t.TempDir()
+	swapConfigMap(t, dir, "ts1", `{"key1": 1}`)
+	path := filepath.Join(dir, "config.json")
+	if err := os.Symlink(filepath.Join("..data", "config.json"), path); err != nil {
+		t.Fatalf("symlink error:%+v", err)
+	}
+
+	links, err := symlinkChain(path)
+	if err != nil || len(links) != 2 || links[0] != path || links[1] != filepath.Join(dir, "..data") {
+		t.Errorf("symlinkChain(%s) = %v; expected [%s %s], error:%+v", path, links, path, filepath.Join(dir, "..data"), err)
+	}
+
+	conf, err := newMConfig(path, configOptions{})
+	if err != nil {
+		t.Fatalf("newMConfig error:%+v", err)
+	}
+	watcher, err := newFileWatcher()
+	if err != nil {
+		t.Fatalf("newFileWatcher error:%+v", err)
+	}
+	defer watcher.close()
+	if _, err := watchFile(watcher, path, nil); err != nil {
+		t.Fatalf("watchFile error:%+v", err)
+	}
+
+	// 内容相同的文件换了目录也需要重新加载
+	swapConfigMap(t, dir, "ts2", `{"key1": 1}`)
+	if !waitEvent(watcher) {
+		t.Errorf("no event after swapping ..data")
+	}
+	if !conf.checkFileDiff() {
+		t.Errorf("checkFileDiff() = false; expected true after swapping ..data")
+	}
+
+	conf, err = conf.reload()
+	if err != nil {
+		t.Fatalf("reload error:%+v", err)
+	}
+	if _, err := watchFile(watcher, path, nil); err != nil {
+		t.Fatalf("watchFile error:%+v", err)
+	}
+	swapConfigMap(t, dir, "ts3", `{"key1": 3}`)
+	if !waitEvent(watcher) {
+		t.Errorf("no event after swapping ..data again")
+	}
+	conf, err = conf.reload()
+	if err != nil {
+		t.Fatalf("reload error:%+v", err)
+	}
+	if val, err := conf.GetInt("key1"); err != nil || val != 3 {
+		t.Errorf("GetInt(key1) = %d; expected 3, error:%+v", val, err)
+	}
+}
@@ -0,0 +1,11 @@
+//go:build !linux
+
+package conf
+
+import (
+	"errors"
+)
+
+func newFileWatcher() (fileWatcher, error) {
+	return nil, errors.New("file watcher is not supported on this platform")
+}